@@ -0,0 +1,153 @@
+package vertigo
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+)
+
+var ErrInvalidAlias = errors.New("feature alias is not valid")
+
+// Aliases maps the feature names used in vertex tags and queries to the feature IDs in the
+// store, in order of preference. Listing both the new and the old ID during a rename, e.g.
+// "six_month_spend": {"spend_6m", "six_month_spend"}, lets either work during the migration.
+type Aliases map[string][]string
+
+// storeFeatureIDs replaces every aliased name in features with its store feature IDs.
+func (a Aliases) storeFeatureIDs(features []string) []string {
+	if len(a) == 0 {
+		return features
+	}
+	ids := make([]string, 0, len(features))
+	for _, f := range features {
+		if storeIDs, ok := a[f]; ok {
+			ids = append(ids, storeIDs...)
+		} else {
+			ids = append(ids, f)
+		}
+	}
+	return dedupe(ids)
+}
+
+// schemaFeatureIDs replaces every aliased name in features with those of its store feature IDs
+// that exist in schema, so a migration fallback can be removed from the store. Names none of
+// whose store feature IDs exist are kept for Query.Validate to report.
+func (a Aliases) schemaFeatureIDs(features []string, schema *Schema) []string {
+	if len(a) == 0 {
+		return features
+	}
+	ids := make([]string, 0, len(features))
+	for _, f := range features {
+		storeIDs, ok := a[f]
+		if !ok {
+			ids = append(ids, f)
+			continue
+		}
+		found := false
+		for _, id := range storeIDs {
+			if _, ok := schema.Feature(id); ok {
+				ids = append(ids, id)
+				found = true
+			}
+		}
+		if !found {
+			ids = append(ids, f)
+		}
+	}
+	return dedupe(ids)
+}
+
+// names returns the sorted aliases that have featureID as a store feature ID.
+func (a Aliases) names(featureID string) []string {
+	var names []string
+	for name, storeIDs := range a {
+		for _, id := range storeIDs {
+			if id == featureID {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// equivalents returns featureID with its other names: its store feature IDs when it is an alias,
+// and its aliases when it is a store feature ID.
+func (a Aliases) equivalents(featureID string) []string {
+	ids := append([]string{featureID}, a[featureID]...)
+	return dedupe(append(ids, a.names(featureID)...))
+}
+
+// validate checks that every alias has store feature IDs with a valid syntax.
+func (a Aliases) validate() error {
+	for name, storeIDs := range a {
+		if len(storeIDs) == 0 {
+			return fmt.Errorf("%w: %v has no feature ids", ErrInvalidAlias, name)
+		}
+		for _, id := range storeIDs {
+			if err := validateFeatureID(id); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidAlias, err)
+			}
+		}
+	}
+	return nil
+}
+
+// applyAliases renames the store features of e to their aliased names. For each alias the first
+// store feature ID with a value is kept, falling back to the first one present, and the other
+// store feature IDs of the alias are removed.
+func applyAliases(e *Entity, aliases Aliases) {
+	if len(aliases) == 0 {
+		return
+	}
+	index := map[string]int{}
+	for i, fd := range e.header.GetFeatureDescriptors() {
+		index[fd.Id] = i
+	}
+
+	rename := map[int]string{}
+	drop := map[int]bool{}
+	for name, storeIDs := range aliases {
+		chosen := -1
+		candidates := append(append(make([]string, 0, len(storeIDs)+1), storeIDs...), name)
+		for _, id := range candidates {
+			i, ok := index[id]
+			if !ok {
+				continue
+			}
+			drop[i] = true
+			if chosen == -1 || (e.data[chosen].GetValue() == nil && e.data[i].GetValue() != nil) {
+				chosen = i
+			}
+		}
+		if chosen != -1 {
+			delete(drop, chosen)
+			rename[chosen] = name
+		}
+	}
+	if len(rename) == 0 {
+		return
+	}
+
+	header := &aiplatformpb.ReadFeatureValuesResponse_Header{EntityType: e.header.GetEntityType()}
+	var data []*aiplatformpb.ReadFeatureValuesResponse_EntityView_Data
+	for i, fd := range e.header.GetFeatureDescriptors() {
+		if drop[i] {
+			continue
+		}
+		id := fd.Id
+		if name, ok := rename[i]; ok {
+			id = name
+		}
+		header.FeatureDescriptors = append(
+			header.FeatureDescriptors,
+			&aiplatformpb.ReadFeatureValuesResponse_FeatureDescriptor{Id: id},
+		)
+		data = append(data, e.data[i])
+	}
+	e.header = header
+	e.data = data
+}
@@ -0,0 +1,235 @@
+package vertigo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+)
+
+func TestAliases_StoreFeatureIDs(t *testing.T) {
+	type test struct {
+		name     string
+		aliases  Aliases
+		features []string
+		expected []string
+	}
+	tests := []test{
+		{
+			name:     "no aliases",
+			features: []string{"segment", "six_month_spend"},
+			expected: []string{"segment", "six_month_spend"},
+		},
+		{
+			name:     "renamed feature with fallback",
+			aliases:  Aliases{"six_month_spend": {"spend_6m", "six_month_spend"}},
+			features: []string{"segment", "six_month_spend"},
+			expected: []string{"segment", "spend_6m", "six_month_spend"},
+		},
+		{
+			name:     "store id also requested",
+			aliases:  Aliases{"six_month_spend": {"spend_6m"}},
+			features: []string{"spend_6m", "six_month_spend"},
+			expected: []string{"spend_6m"},
+		},
+	}
+	for _, tc := range tests {
+		actual := tc.aliases.storeFeatureIDs(tc.features)
+		if !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("%v: expected %v, got %v", tc.name, tc.expected, actual)
+		}
+	}
+}
+
+func TestQuery_BuildRequestAliases(t *testing.T) {
+	cfg, err := NewConfigBuilder().
+		WithProjectID("my-project").
+		WithRegion(nane).
+		WithFeatureStoreName("my_featurestore").
+		WithAlias("my_customer", "six_month_spend", "spend_6m", "six_month_spend").
+		Apply()
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := &Query{EntityType: "my_customer", EntityID: "123", Features: []string{"six_month_spend"}}
+	ids := q.BuildRequest(cfg).FeatureSelector.IdMatcher.Ids
+	if !reflect.DeepEqual(ids, []string{"spend_6m", "six_month_spend"}) {
+		t.Errorf("expected both store ids, got %v", ids)
+	}
+	// other entity types are not aliased
+	q.EntityType = "my_product"
+	ids = q.BuildRequest(cfg).FeatureSelector.IdMatcher.Ids
+	if !reflect.DeepEqual(ids, []string{"six_month_spend"}) {
+		t.Errorf("expected the feature to be unchanged, got %v", ids)
+	}
+}
+
+func TestConfig_ApplyInvalidAlias(t *testing.T) {
+	_, err := NewConfigBuilder().
+		WithProjectID("my-project").
+		WithRegion(nane).
+		WithFeatureStoreName("my_featurestore").
+		WithAlias("my_customer", "six_month_spend").
+		Apply()
+	if !errors.Is(err, ErrInvalidAlias) {
+		t.Errorf("expected ErrInvalidAlias, got %v", err)
+	}
+}
+
+func TestApplyAliases(t *testing.T) {
+	double := func(f float64) *aiplatformpb.FeatureValue {
+		return &aiplatformpb.FeatureValue{Value: &aiplatformpb.FeatureValue_DoubleValue{DoubleValue: f}}
+	}
+	type test struct {
+		name     string
+		values   map[string]*aiplatformpb.FeatureValue
+		expected float64
+	}
+	tests := []test{
+		{
+			name:     "new store id",
+			values:   map[string]*aiplatformpb.FeatureValue{"spend_6m": double(1), "six_month_spend": double(2)},
+			expected: 1,
+		},
+		{
+			name:     "fallback to old store id",
+			values:   map[string]*aiplatformpb.FeatureValue{"spend_6m": nil, "six_month_spend": double(2)},
+			expected: 2,
+		},
+		{
+			name:     "only new store id",
+			values:   map[string]*aiplatformpb.FeatureValue{"spend_6m": double(3)},
+			expected: 3,
+		},
+	}
+	type customer struct {
+		Segment string  `vertex:"segment"`
+		Spend   float64 `vertex:"six_month_spend"`
+	}
+	for _, tc := range tests {
+		tc.values["segment"] = stringFeature("gold")
+		e := newTestEntity(tc.values)
+		applyAliases(e, Aliases{"six_month_spend": {"spend_6m", "six_month_spend"}})
+
+		c := customer{}
+		if err := e.ScanStruct(&c, Strict()); err != nil {
+			t.Errorf("%v: %v", tc.name, err)
+			continue
+		}
+		if c.Spend != tc.expected || c.Segment != "gold" {
+			t.Errorf("%v: expected spend %v, got %+v", tc.name, tc.expected, c)
+		}
+		if e.Has("spend_6m") {
+			t.Errorf("%v: expected store id to be renamed", tc.name)
+		}
+	}
+}
+
+func TestClient_PrepareQueryAliases(t *testing.T) {
+	type test struct {
+		name     string
+		schema   []FeatureSchema
+		features []string
+		expected []string
+		err      error
+	}
+	tests := []test{
+		{
+			name:     "before the migration",
+			schema:   []FeatureSchema{{ID: "six_month_spend", ValueType: DoubleType}},
+			features: []string{"six_month_spend"},
+			expected: []string{"six_month_spend"},
+		},
+		{
+			name:     "during the migration",
+			schema:   []FeatureSchema{{ID: "spend_6m", ValueType: DoubleType}, {ID: "six_month_spend", ValueType: DoubleType}},
+			features: []string{"six_month_spend"},
+			expected: []string{"spend_6m", "six_month_spend"},
+		},
+		{
+			name:     "fallback removed",
+			schema:   []FeatureSchema{{ID: "spend_6m", ValueType: DoubleType}},
+			features: []string{"six_month_spend"},
+			expected: []string{"spend_6m"},
+		},
+		{
+			name:     "no store feature",
+			schema:   []FeatureSchema{{ID: "segment", ValueType: StringType}},
+			features: []string{"six_month_spend"},
+			err:      ErrUnknownFeature,
+		},
+	}
+	for _, tc := range tests {
+		cfg := &Config{
+			ProjectID:        "my-project",
+			Region:           nane,
+			FeatureStoreName: "my_featurestore",
+			Aliases:          map[string]Aliases{"my_customer": {"six_month_spend": {"spend_6m", "six_month_spend"}}},
+		}
+		c := &Client{cfg: cfg, schemas: map[string]*Schema{}}
+		WithQueryValidation(&Schema{EntityType: "my_customer", Features: tc.schema})(c)
+
+		q, err := c.prepareQuery(context.Background(), &Query{EntityType: "my_customer", EntityID: "1", Features: tc.features})
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%v: expected %v, got %v", tc.name, tc.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		ids := q.BuildRequest(cfg).FeatureSelector.IdMatcher.Ids
+		if !reflect.DeepEqual(ids, tc.expected) {
+			t.Errorf("%v: expected %v, got %v", tc.name, tc.expected, ids)
+		}
+	}
+}
+
+func TestValidateStruct_Aliases(t *testing.T) {
+	type customer struct {
+		SixMonthSpend float64 `vertex:"six_month_spend"`
+		Segment       string  `vertex:"segment"`
+	}
+	schema := &Schema{EntityType: "my_customer", Features: []FeatureSchema{
+		{ID: "spend_6m", ValueType: DoubleType},
+		{ID: "segment", ValueType: StringType},
+	}}
+	aliases := Aliases{"six_month_spend": {"spend_6m", "six_month_spend"}}
+
+	report, err := validateStruct(schema, &customer{}, aliases, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Err() != nil || len(report.Uncovered) != 0 {
+		t.Errorf("expected the alias to match spend_6m, got %+v", report)
+	}
+}
+
+func TestApplyAliases_SharedSlice(t *testing.T) {
+	storeIDs := make([]string, 1, 2)
+	storeIDs[0] = "spend_6m"
+	aliases := Aliases{"six_month_spend": storeIDs}
+
+	applyAliases(newTestEntity(map[string]*aiplatformpb.FeatureValue{"spend_6m": stringFeature("1")}), aliases)
+	if extended := storeIDs[:2]; extended[1] != "" {
+		t.Errorf("applyAliases wrote into the config slice: %v", extended)
+	}
+}
+
+func TestClient_NewEntityShortResponse(t *testing.T) {
+	e := newTestEntity(map[string]*aiplatformpb.FeatureValue{
+		"segment":  stringFeature("gold"),
+		"spend_6m": doubleFeature(120.5),
+	})
+	view := &aiplatformpb.ReadFeatureValuesResponse_EntityView{EntityId: e.ID, Data: e.data[:1]}
+	c := &Client{
+		cfg:     &Config{Aliases: map[string]Aliases{"my_customer": {"six_month_spend": {"spend_6m"}}}},
+		metrics: nopMetrics{},
+		now:     time.Now,
+	}
+	if _, err := c.newEntity("my_customer", "", e.header, view); !errors.Is(err, ErrDescriptorMismatch) {
+		t.Errorf("expected ErrDescriptorMismatch, got %v", err)
+	}
+}
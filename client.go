@@ -1,38 +1,218 @@
 package vertigo
 
 import (
-	aiplatform "cloud.google.com/go/aiplatform/apiv1beta1"
 	"context"
 	"errors"
 	"fmt"
-	"google.golang.org/api/option"
-	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+	"io"
 	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	aiplatform "cloud.google.com/go/aiplatform/apiv1beta1"
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+	"google.golang.org/api/option"
 )
 
+// ErrDescriptorMismatch is returned when a response holds a different number of values than
+// feature descriptors.
+var ErrDescriptorMismatch = errors.New("feature descriptors do not match entity view data entries")
+
 // Client is the Vertigo client, which uses the aiplatformv1beta1 gRPC API to communicate
 // with the FeaturestoreOnlineServingClient.
 type Client struct {
-	cfg *Config
-	v   *aiplatform.FeaturestoreOnlineServingClient
+	cfg     *Config
+	v       *aiplatform.FeaturestoreOnlineServingClient
+	metrics Metrics
+	now     func() time.Time
+
+	// conns are the serving clients owned by the Client, closed by Close. They are empty for
+	// the Clients of a Router, which owns the connections instead.
+	conns []*aiplatform.FeaturestoreOnlineServingClient
+
+	// failover holds the featurestore of cfg and its replicas, in failover order.
+	failover *failoverState
+
+	// admin is the FeaturestoreService client used to read feature definitions. It is
+	// created on first use, as most callers only need online serving.
+	adminMu sync.Mutex
+	admin   *aiplatform.FeaturestoreClient
+
+	// validateQueries enables checking queries against schemas, which are keyed by entity
+	// type and fetched on first use unless provided up front.
+	validateQueries bool
+	schemaMu        sync.Mutex
+	schemas         map[string]*Schema
+
+	// sensitivityLabels enables fetching schemas for their sensitivity labels, see
+	// WithSensitivityLabels.
+	sensitivityLabels bool
+
+	// transforms are the derived feature transforms of each entity type.
+	transforms map[string][]Transform
+
+	imputer *imputer
+
+	// shadow repeats sampled reads against a secondary store, see WithShadow.
+	shadow *shadow
+
+	// featureLog logs sampled reads, see WithFeatureLogger.
+	featureLog *featureLog
+}
+
+// ClientOption configures optional behaviour of the Client.
+type ClientOption func(c *Client)
+
+// WithMetrics sets the Metrics that receive the counters emitted by the Client, such as
+// MetricStaleFeatures.
+func WithMetrics(m Metrics) ClientOption {
+	return func(c *Client) {
+		c.metrics = m
+	}
+}
+
+// WithQueryValidation makes GetEntity validate each Query against the schema of its entity
+// type before sending it, and expand the "*" selector to the explicit list of features.
+// Schemas that are not provided are fetched from the feature store on first use and cached.
+func WithQueryValidation(schemas ...*Schema) ClientOption {
+	return func(c *Client) {
+		c.validateQueries = true
+		for _, s := range schemas {
+			c.schemas[s.EntityType] = s
+		}
+	}
+}
+
+// WithSensitivityLabels makes the Client fetch the schema of an entity type before its first read,
+// so features labelled SensitivityLabel are redacted from the start. Without it, or
+// WithQueryValidation, labels only apply once the schema has been fetched, e.g. by FetchSchema,
+// and only the sensitive features of the Config are redacted until then.
+func WithSensitivityLabels() ClientOption {
+	return func(c *Client) {
+		c.sensitivityLabels = true
+	}
+}
+
+// WithTransforms registers transforms that derive features of entityType after every read.
+func WithTransforms(entityType string, transforms ...Transform) ClientOption {
+	return func(c *Client) {
+		c.transforms[entityType] = append(c.transforms[entityType], transforms...)
+	}
+}
+
+// WithImputationStats sets the statistics used by the ImputeMean, ImputeMedian and ImputeMode
+// strategies, see LoadImputationStats. NewClient fails with ErrInvalidImputationStats when a
+// statistic does not match the type of its feature.
+func WithImputationStats(stats ImputationStats) ClientOption {
+	return func(c *Client) {
+		c.imputer.stats = stats
+	}
+}
+
+// WithLastKnownCapacity sets the number of entities whose last known values are kept for the
+// ImputeLastKnown strategy, evicting the least recently read entities. Defaults to
+// DefaultLastKnownCapacity.
+func WithLastKnownCapacity(n int) ClientOption {
+	return func(c *Client) {
+		c.imputer.lastKnown = newLastKnownCache(n)
+	}
 }
 
-// NewClient creates a Client using the provided Config.
-func NewClient(ctx context.Context, cfg *Config) (*Client, error) {
-	fmt.Println(cfg.APIEndpoint())
+// NewClient creates a Client using the provided Config, connected to the endpoint returned by
+// Config.ResolveEndpoint.
+func NewClient(ctx context.Context, cfg *Config, opts ...ClientOption) (*Client, error) {
+	var conns []*aiplatform.FeaturestoreOnlineServingClient
+	dial := func(ctx context.Context, endpoint string) (*aiplatform.FeaturestoreOnlineServingClient, error) {
+		c, err := dialServing(ctx, endpoint)
+		if err == nil {
+			conns = append(conns, c)
+		}
+		return c, err
+	}
+	client, err := newClient(ctx, cfg, dial, opts...)
+	if err != nil {
+		for _, c := range conns {
+			c.Close()
+		}
+		return nil, err
+	}
+	client.conns = conns
+	return client, nil
+}
+
+// dialServing creates the FeaturestoreOnlineServingService client of endpoint.
+func dialServing(ctx context.Context, endpoint string) (*aiplatform.FeaturestoreOnlineServingClient, error) {
 	c, err := aiplatform.NewFeaturestoreOnlineServingClient(
 		ctx,
-		option.WithEndpoint(cfg.APIEndpoint()),
+		option.WithEndpoint(endpoint),
 	)
 
 	if err != nil {
 		return nil, fmt.Errorf("aiplatform.NewFeaturestoreOnlineServingClient: %v", err)
 	}
+	return c, nil
+}
+
+// newClient creates a Client reading from the featurestore of cfg and its replicas through the
+// serving clients returned by dial for their endpoints, which may be shared with other Clients.
+func newClient(
+	ctx context.Context,
+	cfg *Config,
+	dial func(ctx context.Context, endpoint string) (*aiplatform.FeaturestoreOnlineServingClient, error),
+	opts ...ClientOption,
+) (*Client, error) {
+	cfgs := []*Config{cfg}
+	for i, r := range cfg.Replicas {
+		if r.Region == "" {
+			return nil, fmt.Errorf("%w: replica %v has no region", ErrInvalidRegion, i)
+		}
+		cfgs = append(cfgs, cfg.replicaConfig(r))
+	}
+	var targets []*target
+	var primary *aiplatform.FeaturestoreOnlineServingClient
+	for _, targetCfg := range cfgs {
+		endpoint, err := targetCfg.ResolveEndpoint()
+		if err != nil {
+			return nil, err
+		}
+		v, err := dial(ctx, endpoint)
+		if err != nil {
+			return nil, err
+		}
+		if primary == nil {
+			primary = v
+		}
+		targets = append(targets, newTarget(targetCfg, v))
+	}
 
-	return &Client{
-		cfg: cfg,
-		v:   c,
-	}, nil
+	client := &Client{
+		cfg:        cfg,
+		v:          primary,
+		failover:   &failoverState{targets: targets},
+		metrics:    nopMetrics{},
+		now:        time.Now,
+		schemas:    map[string]*Schema{},
+		transforms: map[string][]Transform{},
+		imputer:    newImputer(cfg.Imputation),
+	}
+	for entityType, specs := range cfg.Transforms {
+		for _, spec := range specs {
+			t, err := spec.Build()
+			if err != nil {
+				return nil, err
+			}
+			client.transforms[entityType] = append(client.transforms[entityType], t)
+		}
+	}
+	for _, opt := range opts {
+		opt(client)
+	}
+	if err := client.imputer.stats.validate(); err != nil {
+		return nil, err
+	}
+	client.featureLog.start(client.metrics)
+	return client, nil
 }
 
 // Entity contains the header and data from the aiplatform.ReadFeatureValuesResponse to
@@ -41,51 +221,335 @@ type Entity struct {
 	header *aiplatformpb.ReadFeatureValuesResponse_Header
 	data   []*aiplatformpb.ReadFeatureValuesResponse_EntityView_Data
 	ID     string
+
+	// sensitive holds the IDs of the features whose values are redacted from output.
+	sensitive map[string]bool
 }
 
 // ScanStruct will parse the ReadFeatureValues response from the online serving client
 // and load the features into dst.
 // DST must be a pointer to a struct and have valid `vertex` tags that map to the
 // feature IDs of the entity being parsed.
-func (e *Entity) ScanStruct(dst interface{}) error {
+// DST may also be a *map[string]interface{}, which receives the features as returned by ToMap.
+// Fields whose type implements FeatureUnmarshaler decode themselves, and STRING or BYTES
+// features can be decoded with encoding.TextUnmarshaler, json.Unmarshaler (or plain
+// encoding/json) or proto.Message by adding a `text`, `json` or `proto` option to the
+// tag, e.g. `vertex:"preferences,json"`.
+// A time.Time field tagged with the `timestamp` option, e.g. `vertex:"segment,timestamp"`,
+// receives the generate time of the feature instead of its value.
+// A map[string]interface{} field tagged `vertex:",remaining"` collects every feature that is
+// not mapped to another field, and the Strict option turns unmapped features into an error
+// wrapping ErrUnmappedFeature.
+func (e *Entity) ScanStruct(dst interface{}, opts ...ScanOption) error {
+	if m, ok := dst.(*map[string]interface{}); ok {
+		return e.scanMap(m)
+	}
 	if err := isStructPointer(dst); err != nil {
 		return err
 	}
+	so := &scanOptions{}
+	for _, opt := range opts {
+		opt(so)
+	}
+
+	unmapped, err := e.scanStruct(dst, "")
+	if err != nil {
+		return err
+	}
+	if so.strict && len(unmapped) > 0 {
+		return fmt.Errorf("%w: %v", ErrUnmappedFeature, strings.Join(unmapped, ", "))
+	}
+	return nil
+}
+
+// scanStruct loads the features of the Entity into the struct pointed to by dst, matching
+// prefix+featureID against the vertex tags, and returns the keys of the features that are
+// neither mapped to a field nor collected by a remaining field.
+func (e *Entity) scanStruct(dst interface{}, prefix string) ([]string, error) {
 	mapping := loadMap(dst)
 
 	if len(e.header.FeatureDescriptors) != len(e.data) {
-		return errors.New("feature descriptors do not match entity view data entries")
+		return nil, ErrDescriptorMismatch
 	}
 
 	v := reflect.ValueOf(dst)
+	remaining, err := remainingField(v, mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	var unmapped []string
 	for i, fd := range e.header.FeatureDescriptors {
+		key := prefix + fd.Id
 		fv := e.data[i].GetValue()
-		lookup, ok := mapping[fd.Id]
-		if !ok || fv == nil {
+		lookups, ok := mapping[key]
+		if !ok {
+			if remaining.IsValid() {
+				setRemaining(remaining, key, fv)
+			} else {
+				unmapped = append(unmapped, key)
+			}
 			continue
 		}
-		structField := extractStructField(v, lookup)
+		if fv == nil {
+			continue
+		}
+		for _, lookup := range lookups {
+			structField := extractStructField(v, lookup)
 
-		setStructField(fv, structField)
+			var err error
+			if lookup.options.has(timestampOption) {
+				err = setTimestampField(fv, structField)
+			} else {
+				err = scanField(fv, structField, lookup)
+			}
+			if err != nil && e.Sensitive(fd.Id) {
+				err = redactedError{err}
+			}
+			if err != nil {
+				return nil, fmt.Errorf("feature %v: %w", key, err)
+			}
+		}
 	}
-	return nil
+	return unmapped, nil
 }
 
 // GetEntity calls the Vertex AI Online Serving API and retrieves the response in the
 // form of an Entity and error if one occurs.
+// Store features with an alias in the Config are renamed to the aliased name.
+// With WithQueryValidation, the query is checked against the schema before it is sent.
+// When the Config has a FreshnessPolicy for the entity type, stale values are dropped,
+// defaulted, or cause an error wrapping ErrStaleFeature, according to the policy.
+// Missing values are then imputed according to the ImputationPolicy of the entity type, and
+// derived features are added by its transforms.
+// With WithShadow, a sample of the successful reads is repeated against a secondary store, and
+// with WithFeatureLogger, a sample of the entities read is logged.
 func (c *Client) GetEntity(ctx context.Context, query *Query) (*Entity, error) {
-	res, err := c.v.ReadFeatureValues(ctx, query.BuildRequest(c.cfg))
+	original := query
+	if err := query.CheckAccess(c.cfg); err != nil {
+		return nil, err
+	}
+	query, err := c.prepareQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var res *aiplatformpb.ReadFeatureValuesResponse
+	err = c.withFailover(ctx, query.EntityType, func(ctx context.Context, t *target, _ *latencyBudget) error {
+		res, err = t.read(ctx, query.BuildRequest(t.cfg))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	e, err := c.newEntity(query.EntityType, query.Caller, res.Header, res.EntityView)
+	if err != nil {
+		return nil, err
+	}
+	c.shadowRead(original, e)
+	c.logEntity(ctx, original, e)
+	return e, nil
+}
+
+// GetEntities reads the Feature Values of every entity in query using the
+// StreamingReadFeatureValues RPC. Entities are returned in the order they are streamed by
+// the feature store, and are processed like the result of GetEntity.
+func (c *Client) GetEntities(ctx context.Context, query *BatchQuery) ([]*Entity, error) {
+	q := &Query{EntityType: query.EntityType, Features: query.Features, Caller: query.Caller}
+	if err := q.CheckAccess(c.cfg); err != nil {
+		return nil, err
+	}
+	q, err := c.prepareQuery(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	query = &BatchQuery{
+		EntityType: query.EntityType,
+		EntityIDs:  query.EntityIDs,
+		Features:   q.Features,
+		Store:      query.Store,
+		Caller:     query.Caller,
+		resolved:   q.resolved,
+	}
+
+	var entities []*Entity
+	err = c.withFailover(ctx, query.EntityType, func(ctx context.Context, t *target, budget *latencyBudget) error {
+		stream, err := t.stream(ctx, query.BuildRequest(t.cfg))
+		if err != nil {
+			return err
+		}
+		entities, err = c.receiveEntities(query.EntityType, query.Caller, &budgetedStream{stream, budget})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entities {
+		c.logEntity(ctx, &Query{
+			EntityType: query.EntityType,
+			EntityID:   e.ID,
+			Features:   query.Features,
+			Store:      query.Store,
+			Caller:     query.Caller,
+		}, e)
+	}
+	return entities, nil
+}
+
+// featureValuesStream is the receiving side of the StreamingReadFeatureValues RPC.
+type featureValuesStream interface {
+	Recv() (*aiplatformpb.ReadFeatureValuesResponse, error)
+}
+
+// receiveEntities reads every Entity from stream. The header is only guaranteed to be set
+// on the first response, so it is carried over to the entity views that follow.
+func (c *Client) receiveEntities(entityType, caller string, stream featureValuesStream) ([]*Entity, error) {
+	var header *aiplatformpb.ReadFeatureValuesResponse_Header
+	var entities []*Entity
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			return entities, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if res.Header != nil {
+			header = res.Header
+		}
+		if res.EntityView == nil {
+			continue
+		}
+		if header == nil {
+			return nil, errors.New("stream sent an entity view before the header")
+		}
+		e, err := c.newEntity(entityType, caller, header, res.EntityView)
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, e)
+	}
+}
+
+// prepareQuery replaces the features derived by transforms with their sources, then validates
+// and expands query when WithQueryValidation is enabled. Aliased names are replaced with those
+// of their store feature IDs that exist in the schema.
+func (c *Client) prepareQuery(ctx context.Context, query *Query) (*Query, error) {
+	if err := c.loadSensitivityLabels(ctx, query.EntityType); err != nil {
+		return nil, err
+	}
+	if transforms := c.transforms[query.EntityType]; len(transforms) > 0 {
+		stripped := *query
+		stripped.Features = withoutTargets(query.Features, transforms)
+		query = &stripped
+	}
+	if !c.validateQueries {
+		return query, nil
+	}
+	schema, err := c.schema(ctx, query.EntityType)
 	if err != nil {
 		return nil, err
 	}
-	return &Entity{
-		ID:     res.EntityView.EntityId,
-		header: res.Header,
-		data:   res.EntityView.Data,
-	}, nil
+	resolved := *query
+	resolved.Features = c.cfg.Aliases[query.EntityType].schemaFeatureIDs(query.Features, schema)
+	if err := resolved.Validate(schema); err != nil {
+		return nil, err
+	}
+	expanded := resolved.Expand(schema)
+	expanded.resolved = true
+	return expanded, nil
+}
+
+// newEntity builds the Entity for a response and applies the read policies of its entity type.
+func (c *Client) newEntity(
+	entityType, caller string,
+	header *aiplatformpb.ReadFeatureValuesResponse_Header,
+	view *aiplatformpb.ReadFeatureValuesResponse_EntityView,
+) (*Entity, error) {
+	if len(header.GetFeatureDescriptors()) != len(view.GetData()) {
+		return nil, ErrDescriptorMismatch
+	}
+	e := &Entity{
+		ID:     view.GetEntityId(),
+		header: header,
+		data:   view.GetData(),
+	}
+	applyAliases(e, c.cfg.Aliases[entityType])
+	restrict(e, c.cfg.sensitivityPolicy(entityType), caller)
+	e.sensitive = c.sensitiveFeatures(entityType)
+	if policy, ok := c.cfg.Freshness[entityType]; ok {
+		if err := enforceFreshness(e, entityType, policy, c.now(), c.metrics); err != nil {
+			return nil, err
+		}
+	}
+	if c.imputer != nil {
+		if err := c.imputer.apply(e, entityType, c.metrics); err != nil {
+			return nil, err
+		}
+	}
+	if transforms := c.transforms[entityType]; len(transforms) > 0 {
+		if err := applyTransforms(e, transforms); err != nil {
+			return nil, fmt.Errorf("transform %v: %w", entityType, err)
+		}
+	}
+	return e, nil
 }
 
-// Close closes the underlying vertex AI gRPC client.
+// schema returns the cached schema of entityType, fetching it on first use.
+func (c *Client) schema(ctx context.Context, entityType string) (*Schema, error) {
+	c.schemaMu.Lock()
+	s, ok := c.schemas[entityType]
+	c.schemaMu.Unlock()
+	if ok {
+		return s, nil
+	}
+
+	s, err := c.FetchSchema(ctx, entityType)
+	if err != nil {
+		return nil, err
+	}
+	c.schemaMu.Lock()
+	defer c.schemaMu.Unlock()
+	c.schemas[entityType] = s
+	return s, nil
+}
+
+// featurestoreClient returns the FeaturestoreService client, creating it on first use.
+func (c *Client) featurestoreClient(ctx context.Context) (*aiplatform.FeaturestoreClient, error) {
+	c.adminMu.Lock()
+	defer c.adminMu.Unlock()
+	if c.admin != nil {
+		return c.admin, nil
+	}
+	admin, err := aiplatform.NewFeaturestoreClient(ctx, option.WithEndpoint(c.cfg.APIEndpoint()))
+	if err != nil {
+		return nil, fmt.Errorf("aiplatform.NewFeaturestoreClient: %v", err)
+	}
+	c.admin = admin
+	return admin, nil
+}
+
+// Close closes the underlying vertex AI gRPC clients.
 func (c *Client) Close() error {
-	return c.v.Close()
+	c.shadow.wait()
+	c.featureLog.close()
+	if err := c.closeAdmin(); err != nil {
+		return err
+	}
+	for _, conn := range c.conns {
+		if err := conn.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closeAdmin closes the FeaturestoreService client, if it was created.
+func (c *Client) closeAdmin() error {
+	c.adminMu.Lock()
+	defer c.adminMu.Unlock()
+	if c.admin != nil {
+		return c.admin.Close()
+	}
+	return nil
 }
@@ -2,8 +2,11 @@ package vertigo
 
 import (
 	"context"
+	"io"
 	"os"
+	"sort"
 	"testing"
+	"time"
 
 	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
 )
@@ -73,3 +76,92 @@ func TestEntity_ScanStruct(t *testing.T) {
 		t.Errorf("Expected 25 and 50, got %v, and %v", c.MyFeature, c.AnotherFeature)
 	}
 }
+
+// newTestEntity builds an Entity from a map of feature ID to value, ordering the
+// feature descriptors by ID.
+func newTestEntity(values map[string]*aiplatformpb.FeatureValue) *Entity {
+	ids := make([]string, 0, len(values))
+	for id := range values {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	e := &Entity{
+		header: &aiplatformpb.ReadFeatureValuesResponse_Header{EntityType: "my_entity"},
+		ID:     "123",
+	}
+	for _, id := range ids {
+		e.header.FeatureDescriptors = append(
+			e.header.FeatureDescriptors,
+			&aiplatformpb.ReadFeatureValuesResponse_FeatureDescriptor{Id: id},
+		)
+		e.data = append(e.data, &aiplatformpb.ReadFeatureValuesResponse_EntityView_Data{
+			Data: &aiplatformpb.ReadFeatureValuesResponse_EntityView_Data_Value{Value: values[id]},
+		})
+	}
+	return e
+}
+
+// fakeStream replays responses of the StreamingReadFeatureValues RPC.
+type fakeStream struct {
+	responses []*aiplatformpb.ReadFeatureValuesResponse
+	err       error
+}
+
+func (s *fakeStream) Recv() (*aiplatformpb.ReadFeatureValuesResponse, error) {
+	if len(s.responses) == 0 {
+		if s.err != nil {
+			return nil, s.err
+		}
+		return nil, io.EOF
+	}
+	res := s.responses[0]
+	s.responses = s.responses[1:]
+	return res, nil
+}
+
+func TestClient_ReceiveEntities(t *testing.T) {
+	header := &aiplatformpb.ReadFeatureValuesResponse_Header{
+		EntityType: "my_customer",
+		FeatureDescriptors: []*aiplatformpb.ReadFeatureValuesResponse_FeatureDescriptor{
+			{Id: "segment"},
+		},
+	}
+	view := func(id, segment string) *aiplatformpb.ReadFeatureValuesResponse_EntityView {
+		return &aiplatformpb.ReadFeatureValuesResponse_EntityView{
+			EntityId: id,
+			Data: []*aiplatformpb.ReadFeatureValuesResponse_EntityView_Data{
+				{Data: &aiplatformpb.ReadFeatureValuesResponse_EntityView_Data_Value{Value: stringFeature(segment)}},
+			},
+		}
+	}
+	c := &Client{cfg: &Config{}, metrics: nopMetrics{}, now: time.Now}
+
+	entities, err := c.receiveEntities("my_customer", "", &fakeStream{
+		responses: []*aiplatformpb.ReadFeatureValuesResponse{
+			{Header: header},
+			{EntityView: view("1", "gold")},
+			{EntityView: view("2", "silver")},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entities) != 2 || entities[1].ID != "2" {
+		t.Fatalf("unexpected entities: %v", entities)
+	}
+	if s, ok := entities[1].String("segment"); !ok || s != "silver" {
+		t.Errorf("expected silver, got %v", s)
+	}
+
+	if _, err := c.receiveEntities("my_customer", "", &fakeStream{err: io.ErrUnexpectedEOF}); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected stream error, got %v", err)
+	}
+
+	_, err = c.receiveEntities("my_customer", "", &fakeStream{
+		responses: []*aiplatformpb.ReadFeatureValuesResponse{{EntityView: view("1", "gold")}},
+	})
+	if err == nil {
+		t.Error("expected an error for an entity view without a header")
+	}
+}
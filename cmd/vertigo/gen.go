@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/bradleybonitatibus/vertigo"
+)
+
+// goTypes maps each feature value type to the Go type ScanStruct loads it into.
+var goTypes = map[vertigo.ValueType]string{
+	vertigo.BoolType:        "bool",
+	vertigo.BoolArrayType:   "[]bool",
+	vertigo.DoubleType:      "float64",
+	vertigo.DoubleArrayType: "[]float64",
+	vertigo.Int64Type:       "int64",
+	vertigo.Int64ArrayType:  "[]int64",
+	vertigo.StringType:      "string",
+	vertigo.StringArrayType: "[]string",
+	vertigo.BytesType:       "[]byte",
+}
+
+// initialisms are rendered in upper case in generated identifiers, following Go naming conventions.
+var initialisms = map[string]bool{
+	"api": true, "id": true, "ip": true, "json": true, "url": true, "uuid": true,
+}
+
+// genStruct implements `vertigo gen struct`.
+func genStruct(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("gen struct", flag.ContinueOnError)
+	sf := &schemaFlags{}
+	sf.register(fs)
+	pkg := fs.String("package", "features", "package name of the generated file")
+	typeName := fs.String("type", "", "name of the generated struct, defaults to the entity type in CamelCase")
+	out := fs.String("o", "", "write the generated code to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	schema, err := sf.load(ctx)
+	if err != nil {
+		return err
+	}
+	src, err := generateStruct(schema, *pkg, *typeName)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(*out, src, 0o644)
+}
+
+// generateStruct renders a gofmt'd Go file declaring a struct with a field, and matching
+// `json` and `vertex` tags, for every feature of schema.
+func generateStruct(schema *vertigo.Schema, pkg, typeName string) ([]byte, error) {
+	if typeName == "" {
+		typeName = exportedName(schema.EntityType)
+	}
+	if typeName == "" {
+		return nil, fmt.Errorf("cannot derive a type name, set -type or the schema's entity type")
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by vertigo gen struct; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %v\n\n", pkg)
+	fmt.Fprintf(&b, "// %v holds the features of the %v entity type.\n", typeName, schema.EntityType)
+	fmt.Fprintf(&b, "type %v struct {\n", typeName)
+	for _, f := range schema.Features {
+		goType, ok := goTypes[f.ValueType]
+		if !ok {
+			fmt.Fprintf(&b, "// %v is skipped, value type %v is not supported.\n\n", f.ID, f.ValueType)
+			continue
+		}
+		if f.Description != "" {
+			for _, line := range strings.Split(strings.TrimSpace(f.Description), "\n") {
+				fmt.Fprintf(&b, "// %v\n", line)
+			}
+		}
+		fmt.Fprintf(&b, "%v %v `json:\"%v\" vertex:\"%v\"`\n", exportedName(f.ID), goType, f.ID, f.ID)
+	}
+	fmt.Fprintf(&b, "}\n")
+
+	return format.Source(b.Bytes())
+}
+
+// exportedName converts a snake_case resource ID into an exported Go identifier.
+func exportedName(id string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(id, "_") {
+		if part == "" {
+			continue
+		}
+		if initialisms[part] {
+			b.WriteString(strings.ToUpper(part))
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
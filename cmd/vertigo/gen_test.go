@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bradleybonitatibus/vertigo"
+)
+
+func TestExportedName(t *testing.T) {
+	type test struct {
+		id   string
+		want string
+	}
+
+	tests := []test{
+		{id: "segment", want: "Segment"},
+		{id: "six_month_spend", want: "SixMonthSpend"},
+		{id: "customer_id", want: "CustomerID"},
+		{id: "_leading__double", want: "LeadingDouble"},
+	}
+
+	for _, tc := range tests {
+		if got := exportedName(tc.id); got != tc.want {
+			t.Errorf("%v: expected %v, got %v", tc.id, tc.want, got)
+		}
+	}
+}
+
+func TestGenStruct(t *testing.T) {
+	schema := &vertigo.Schema{
+		EntityType: "my_customer",
+		Features: []vertigo.FeatureSchema{
+			{ID: "segment", ValueType: vertigo.StringType, Description: "Marketing segment."},
+			{ID: "market_audiences", ValueType: vertigo.StringArrayType},
+			{ID: "six_month_spend", ValueType: vertigo.DoubleType},
+			{ID: "another_numeric_feature", ValueType: vertigo.Int64Type},
+		},
+	}
+	b, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "schema.json")
+	if err := os.WriteFile(filename, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := run(context.Background(), []string{"gen", "struct", "-schema", filename, "-package", "customers"}, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "// Code generated by vertigo gen struct; DO NOT EDIT.\n" +
+		"\n" +
+		"package customers\n" +
+		"\n" +
+		"// MyCustomer holds the features of the my_customer entity type.\n" +
+		"type MyCustomer struct {\n" +
+		"\t// Marketing segment.\n" +
+		"\tSegment               string   `json:\"segment\" vertex:\"segment\"`\n" +
+		"\tMarketAudiences       []string `json:\"market_audiences\" vertex:\"market_audiences\"`\n" +
+		"\tSixMonthSpend         float64  `json:\"six_month_spend\" vertex:\"six_month_spend\"`\n" +
+		"\tAnotherNumericFeature int64    `json:\"another_numeric_feature\" vertex:\"another_numeric_feature\"`\n" +
+		"}\n"
+	if out.String() != want {
+		t.Errorf("unexpected output:\n%v", out.String())
+	}
+}
@@ -0,0 +1,106 @@
+// Command vertigo is a companion CLI for the vertigo package. It works with the feature
+// definitions of a Vertex AI Featurestore entity type.
+//
+// Usage:
+//
+//	vertigo gen struct -entity-type my_customer [-schema file.json | -project p -region r -featurestore fs]
+//	vertigo schema export -entity-type my_customer -project p -region r -featurestore fs -o my_customer.yaml
+//	vertigo schema diff -base my_customer.yaml [-schema other.yaml | -project p -region r -featurestore fs]
+//
+// schema diff exits with a non-zero status when the compared schema has breaking changes,
+// i.e. removed features or changed value types.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bradleybonitatibus/vertigo"
+)
+
+const usage = `usage: vertigo <command> [flags]
+
+commands:
+  gen struct      generate a Go struct from an entity type's feature definitions
+  schema export   write an entity type's feature definitions to a YAML or JSON snapshot
+  schema diff     compare a snapshot against the featurestore or another snapshot
+`
+
+// commands maps "<command> <subcommand>" to its implementation.
+var commands = map[string]func(ctx context.Context, args []string, stdout io.Writer) error{
+	"gen struct":    genStruct,
+	"schema export": schemaExport,
+	"schema diff":   schemaDiff,
+}
+
+func main() {
+	if err := run(context.Background(), os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "vertigo:", err)
+		os.Exit(1)
+	}
+}
+
+// run dispatches args to the matching subcommand.
+func run(ctx context.Context, args []string, stdout io.Writer) error {
+	if len(args) >= 2 {
+		if cmd, ok := commands[args[0]+" "+args[1]]; ok {
+			return cmd(ctx, args[2:], stdout)
+		}
+	}
+	fmt.Fprint(os.Stderr, usage)
+	return errors.New("unknown command")
+}
+
+// schemaFlags are the flags shared by every command that reads an entity type's schema,
+// either from a local file or from the featurestore admin API.
+type schemaFlags struct {
+	entityType   string
+	schemaFile   string
+	projectID    string
+	region       string
+	featureStore string
+}
+
+func (f *schemaFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.entityType, "entity-type", "", "entity type ID")
+	fs.StringVar(&f.schemaFile, "schema", "", "read the schema from a local file instead of the featurestore")
+	fs.StringVar(&f.projectID, "project", "", "GCP project ID of the featurestore")
+	fs.StringVar(&f.region, "region", vertigo.DefaultRegion, "GCP region of the featurestore")
+	fs.StringVar(&f.featureStore, "featurestore", "", "featurestore name")
+}
+
+// load reads the schema from the file given by -schema, or fetches it from the featurestore.
+func (f *schemaFlags) load(ctx context.Context) (*vertigo.Schema, error) {
+	if f.schemaFile != "" {
+		s, err := vertigo.LoadSchema(f.schemaFile)
+		if err != nil {
+			return nil, err
+		}
+		if s.EntityType == "" {
+			s.EntityType = f.entityType
+		}
+		return s, nil
+	}
+
+	if f.entityType == "" {
+		return nil, errors.New("-entity-type is required")
+	}
+	cfg, err := vertigo.NewConfigBuilder().
+		WithProjectID(f.projectID).
+		WithRegion(f.region).
+		WithFeatureStoreName(f.featureStore).
+		Apply()
+	if err != nil {
+		return nil, err
+	}
+	client, err := vertigo.NewClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	return client.FetchSchema(ctx, f.entityType)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/bradleybonitatibus/vertigo"
+)
+
+// errBreakingChanges makes schema diff exit with a non-zero status.
+var errBreakingChanges = errors.New("schema has breaking changes")
+
+// schemaExport implements `vertigo schema export`.
+func schemaExport(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("schema export", flag.ContinueOnError)
+	sf := &schemaFlags{}
+	sf.register(fs)
+	out := fs.String("o", "", "snapshot file to write, the extension selects YAML or JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return errors.New("-o is required")
+	}
+
+	schema, err := sf.load(ctx)
+	if err != nil {
+		return err
+	}
+	if err := vertigo.WriteSchema(*out, schema); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "wrote %v features of %v to %v\n", len(schema.Features), schema.EntityType, *out)
+	return nil
+}
+
+// schemaDiff implements `vertigo schema diff`. The base snapshot is compared against the
+// snapshot given by -schema, or the live featurestore.
+func schemaDiff(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("schema diff", flag.ContinueOnError)
+	sf := &schemaFlags{}
+	sf.register(fs)
+	basePath := fs.String("base", "", "snapshot file to compare against")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *basePath == "" {
+		return errors.New("-base is required")
+	}
+
+	base, err := vertigo.LoadSchema(*basePath)
+	if err != nil {
+		return err
+	}
+	if sf.entityType == "" {
+		sf.entityType = base.EntityType
+	}
+	current, err := sf.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	changes := vertigo.DiffSchemas(base, current)
+	if len(changes) == 0 {
+		fmt.Fprintln(stdout, "no changes")
+		return nil
+	}
+	fmt.Fprintln(stdout, changes)
+	if breaking := changes.Breaking(); len(breaking) > 0 {
+		return fmt.Errorf("%w: %v", errBreakingChanges, len(breaking))
+	}
+	return nil
+}
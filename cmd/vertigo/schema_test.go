@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bradleybonitatibus/vertigo"
+)
+
+func TestSchemaDiff(t *testing.T) {
+	dir := t.TempDir()
+	base := &vertigo.Schema{
+		EntityType: "my_customer",
+		Features: []vertigo.FeatureSchema{
+			{ID: "segment", ValueType: vertigo.StringType},
+			{ID: "six_month_spend", ValueType: vertigo.DoubleType},
+		},
+	}
+	added := &vertigo.Schema{
+		EntityType: "my_customer",
+		Features:   append(base.Features, vertigo.FeatureSchema{ID: "spend_6m", ValueType: vertigo.DoubleType}),
+	}
+	removed := &vertigo.Schema{
+		EntityType: "my_customer",
+		Features:   base.Features[:1],
+	}
+
+	write := func(name string, s *vertigo.Schema) string {
+		filename := filepath.Join(dir, name)
+		if err := vertigo.WriteSchema(filename, s); err != nil {
+			t.Fatal(err)
+		}
+		return filename
+	}
+	basePath := write("base.yaml", base)
+
+	type test struct {
+		name    string
+		other   string
+		want    string
+		wantErr error
+	}
+
+	tests := []test{
+		{name: "unchanged", other: write("same.json", base), want: "no changes"},
+		{name: "added", other: write("added.yaml", added), want: "+ spend_6m (DOUBLE)"},
+		{name: "removed", other: write("removed.yaml", removed), want: "- six_month_spend (DOUBLE)", wantErr: errBreakingChanges},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := run(context.Background(), []string{"schema", "diff", "-base", basePath, "-schema", tc.other}, &out)
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("expected error %v, got %v", tc.wantErr, err)
+			}
+			if !strings.Contains(out.String(), tc.want) {
+				t.Errorf("expected output to contain %q, got %q", tc.want, out.String())
+			}
+		})
+	}
+}
+
+func TestSchemaExport(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.json")
+	if err := vertigo.WriteSchema(in, &vertigo.Schema{
+		EntityType: "my_customer",
+		Features:   []vertigo.FeatureSchema{{ID: "segment", ValueType: vertigo.StringType}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out.yaml")
+
+	var stdout bytes.Buffer
+	if err := run(context.Background(), []string{"schema", "export", "-schema", in, "-o", out}, &stdout); err != nil {
+		t.Fatal(err)
+	}
+	s, err := vertigo.LoadSchema(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Features) != 1 || s.Features[0].ValueType != vertigo.StringType {
+		t.Errorf("unexpected exported schema: %+v", s)
+	}
+}
@@ -3,6 +3,8 @@ package vertigo
 import (
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 )
 
 // DefaultRegion is the region
@@ -27,14 +29,69 @@ type Config struct {
 
 	// FeatureStoreName is the name of the feature store.
 	FeatureStoreName string `json:"feature_store_name" yaml:"feature_store_name"`
+
+	// AllowedRegions replaces KnownRegions as the list Region is validated against, e.g. for
+	// regions launched after this version of vertigo.
+	AllowedRegions []string `json:"allowed_regions,omitempty" yaml:"allowed_regions,omitempty"`
+
+	// Endpoint overrides the HOST:PORT of the Vertex AI API, e.g. for a custom domain or a
+	// VPC Service Controls restricted endpoint. It takes precedence over EndpointTemplate.
+	Endpoint string `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+
+	// EndpointTemplate builds the HOST:PORT of the Vertex AI API from the "{region}" and
+	// "{project}" placeholders, e.g. "{region}-aiplatform-myendpoint.p.googleapis.com:443" for a
+	// Private Service Connect endpoint.
+	EndpointTemplate string `json:"endpoint_template,omitempty" yaml:"endpoint_template,omitempty"`
+
+	// Replicas are copies of the featurestore that reads fail over to, in order, according to
+	// the Failover policy.
+	Replicas []Replica `json:"replicas,omitempty" yaml:"replicas,omitempty"`
+
+	// Failover controls when reads fail over to the Replicas and back.
+	Failover FailoverPolicy `json:"failover,omitempty" yaml:"failover,omitempty"`
+
+	// Freshness holds the FreshnessPolicy of each entity type, keyed by entity type ID.
+	// Entity types without a policy are never checked for stale values.
+	Freshness map[string]FreshnessPolicy `json:"freshness,omitempty" yaml:"freshness,omitempty"`
+
+	// Transforms declares the derived features of each entity type, keyed by entity type ID.
+	// They run before the transforms registered with WithTransforms.
+	Transforms map[string][]TransformSpec `json:"transforms,omitempty" yaml:"transforms,omitempty"`
+
+	// Imputation holds the ImputationPolicy of each entity type, keyed by entity type ID.
+	// Missing values are imputed before transforms run.
+	Imputation map[string]ImputationPolicy `json:"imputation,omitempty" yaml:"imputation,omitempty"`
+
+	// Aliases holds the feature Aliases of each entity type, keyed by entity type ID. They are
+	// applied to the features of every request, and read entities use the aliased names, so
+	// freshness, imputation and transforms are configured with the aliased names too.
+	Aliases map[string]Aliases `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+
+	// Sensitivity holds the SensitivityPolicy of each entity type, keyed by entity type ID.
+	// Like the other policies, it uses the aliased feature names.
+	Sensitivity map[string]SensitivityPolicy `json:"sensitivity,omitempty" yaml:"sensitivity,omitempty"`
 }
 
 // ConfigBuilder provides a fluent interface for building the Vertigo Config.
+// Values are merged in the order defaults, FromFile sources, FromEnv sources, then the other
+// methods, whatever order they are called in.
 // If Region is not set, it will fall back to the DefaultRegion value.
 type ConfigBuilder interface {
 	WithRegion(region string) ConfigBuilder
 	WithProjectID(projectID string) ConfigBuilder
 	WithFeatureStoreName(featureStore string) ConfigBuilder
+	WithAllowedRegions(regions ...string) ConfigBuilder
+	WithEndpoint(endpoint string) ConfigBuilder
+	WithEndpointTemplate(template string) ConfigBuilder
+	WithReplicas(replicas ...Replica) ConfigBuilder
+	WithFailoverPolicy(policy FailoverPolicy) ConfigBuilder
+	WithFreshnessPolicy(entityType string, policy FreshnessPolicy) ConfigBuilder
+	WithTransforms(entityType string, specs ...TransformSpec) ConfigBuilder
+	WithImputationPolicy(entityType string, policy ImputationPolicy) ConfigBuilder
+	WithAlias(entityType, name string, featureIDs ...string) ConfigBuilder
+	WithSensitivityPolicy(entityType string, policy SensitivityPolicy) ConfigBuilder
+	FromFile(filename string) ConfigBuilder
+	FromEnv(prefix string) ConfigBuilder
 	Apply() (*Config, error)
 }
 
@@ -43,30 +100,113 @@ type ConfigBuilder interface {
 type builderFunc func(cfg *Config)
 
 type builder struct {
+	sources []configSource
 	actions []builderFunc
 }
 
-// Apply applies all the changes and validates the Config fields.
+// Apply merges the defaults, the FromFile sources, the FromEnv sources and the changes made with
+// the other methods, each taking precedence over the ones before it, then validates the Config.
+// Sources of the same kind are merged in the order they were added.
 // If Region is not set, it will fall back to the DefaultRegion value.
 func (b *builder) Apply() (*Config, error) {
 	cfg := &Config{}
+	origins := map[string]string{}
+	sources := append([]configSource(nil), b.sources...)
+	sort.SliceStable(sources, func(i, j int) bool {
+		return !sources[i].env && sources[j].env
+	})
+	for _, src := range sources {
+		loaded, err := src.load()
+		if err != nil {
+			return nil, err
+		}
+		mergeConfig(cfg, loaded, src.origin, origins)
+	}
+
+	overrides := &Config{}
 	for _, a := range b.actions {
-		a(cfg)
+		a(overrides)
 	}
+	mergeConfig(cfg, overrides, func(string) string { return "ConfigBuilder" }, origins)
 
 	if cfg.Region == "" {
 		cfg.Region = DefaultRegion
 	}
 
-	if cfg.ProjectID == "" {
-		return nil, ErrInvalidProjectID
+	if err := cfg.validate(origins); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// validate checks the Config fields. origins holds the source of each value, keyed like
+// mergeConfig keys them, and is used to name the source of a bad value in the error.
+func (c *Config) validate(origins map[string]string) error {
+	from := func(key string) string {
+		if origin, ok := origins[key]; ok {
+			return fmt.Sprintf(" (from %v)", origin)
+		}
+		return ""
 	}
 
-	if cfg.FeatureStoreName == "" {
-		return nil, ErrInvalidFeatureStoreName
+	if c.ProjectID == "" {
+		return fmt.Errorf("%w: project_id is not set", ErrInvalidProjectID)
 	}
 
-	return cfg, nil
+	if c.FeatureStoreName == "" {
+		return fmt.Errorf("%w: feature_store_name is not set", ErrInvalidFeatureStoreName)
+	}
+
+	if _, err := c.ResolveEndpoint(); err != nil {
+		return fmt.Errorf("%w%v", err, from("region"))
+	}
+
+	for i, r := range c.Replicas {
+		if r.Region == "" {
+			return fmt.Errorf("%w: replica %v has no region%v", ErrInvalidRegion, i, from("replicas"))
+		}
+		if _, err := c.replicaConfig(r).ResolveEndpoint(); err != nil {
+			return fmt.Errorf("%w: replica %v%v", err, i, from("replicas"))
+		}
+	}
+
+	if err := c.Failover.validate(); err != nil {
+		return fmt.Errorf("%w%v", err, from("failover"))
+	}
+
+	for entityType, policy := range c.Freshness {
+		if err := policy.validate(); err != nil {
+			return fmt.Errorf("%w: entity type %v%v", err, entityType, from("freshness."+entityType))
+		}
+	}
+
+	for entityType, aliases := range c.Aliases {
+		if err := aliases.validate(); err != nil {
+			return fmt.Errorf("%w: entity type %v%v", err, entityType, from("aliases."+entityType))
+		}
+	}
+
+	for entityType, policy := range c.Sensitivity {
+		if err := policy.validate(c.Aliases[entityType]); err != nil {
+			return fmt.Errorf("%w: entity type %v%v", err, entityType, from("sensitivity."+entityType))
+		}
+	}
+
+	for entityType, policy := range c.Imputation {
+		if err := policy.validate(); err != nil {
+			return fmt.Errorf("%w: entity type %v%v", err, entityType, from("imputation."+entityType))
+		}
+	}
+
+	for entityType, specs := range c.Transforms {
+		for _, spec := range specs {
+			if _, err := spec.Build(); err != nil {
+				return fmt.Errorf("%w: entity type %v%v", err, entityType, from("transforms."+entityType))
+			}
+		}
+	}
+
+	return nil
 }
 
 // WithRegion sets the GCP Region for the Config.
@@ -93,6 +233,105 @@ func (b *builder) WithFeatureStoreName(featureStore string) ConfigBuilder {
 	return b
 }
 
+// WithAllowedRegions sets the regions the Region is validated against, instead of KnownRegions.
+func (b *builder) WithAllowedRegions(regions ...string) ConfigBuilder {
+	b.actions = append(b.actions, func(cfg *Config) {
+		cfg.AllowedRegions = regions
+	})
+	return b
+}
+
+// WithEndpoint sets the HOST:PORT of the Vertex AI API, overriding the regional endpoint.
+func (b *builder) WithEndpoint(endpoint string) ConfigBuilder {
+	b.actions = append(b.actions, func(cfg *Config) {
+		cfg.Endpoint = endpoint
+	})
+	return b
+}
+
+// WithEndpointTemplate sets the template the HOST:PORT of the Vertex AI API is built from.
+func (b *builder) WithEndpointTemplate(template string) ConfigBuilder {
+	b.actions = append(b.actions, func(cfg *Config) {
+		cfg.EndpointTemplate = template
+	})
+	return b
+}
+
+// WithReplicas adds replicas of the featurestore that reads fail over to, in order.
+func (b *builder) WithReplicas(replicas ...Replica) ConfigBuilder {
+	b.actions = append(b.actions, func(cfg *Config) {
+		cfg.Replicas = append(cfg.Replicas, replicas...)
+	})
+	return b
+}
+
+// WithFailoverPolicy sets when reads fail over to the replicas of the featurestore.
+func (b *builder) WithFailoverPolicy(policy FailoverPolicy) ConfigBuilder {
+	b.actions = append(b.actions, func(cfg *Config) {
+		cfg.Failover = policy
+	})
+	return b
+}
+
+// WithFreshnessPolicy sets the FreshnessPolicy used for the feature values of entityType.
+func (b *builder) WithFreshnessPolicy(entityType string, policy FreshnessPolicy) ConfigBuilder {
+	b.actions = append(b.actions, func(cfg *Config) {
+		if cfg.Freshness == nil {
+			cfg.Freshness = map[string]FreshnessPolicy{}
+		}
+		cfg.Freshness[entityType] = policy
+	})
+	return b
+}
+
+// WithTransforms adds declarative transforms that derive features of entityType.
+func (b *builder) WithTransforms(entityType string, specs ...TransformSpec) ConfigBuilder {
+	b.actions = append(b.actions, func(cfg *Config) {
+		if cfg.Transforms == nil {
+			cfg.Transforms = map[string][]TransformSpec{}
+		}
+		cfg.Transforms[entityType] = append(cfg.Transforms[entityType], specs...)
+	})
+	return b
+}
+
+// WithImputationPolicy sets the ImputationPolicy used for the missing values of entityType.
+func (b *builder) WithImputationPolicy(entityType string, policy ImputationPolicy) ConfigBuilder {
+	b.actions = append(b.actions, func(cfg *Config) {
+		if cfg.Imputation == nil {
+			cfg.Imputation = map[string]ImputationPolicy{}
+		}
+		cfg.Imputation[entityType] = policy
+	})
+	return b
+}
+
+// WithAlias maps the feature name used in tags and queries of entityType to the store
+// featureIDs, in order of preference.
+func (b *builder) WithAlias(entityType, name string, featureIDs ...string) ConfigBuilder {
+	b.actions = append(b.actions, func(cfg *Config) {
+		if cfg.Aliases == nil {
+			cfg.Aliases = map[string]Aliases{}
+		}
+		if cfg.Aliases[entityType] == nil {
+			cfg.Aliases[entityType] = Aliases{}
+		}
+		cfg.Aliases[entityType][name] = featureIDs
+	})
+	return b
+}
+
+// WithSensitivityPolicy sets the sensitive and restricted features of entityType.
+func (b *builder) WithSensitivityPolicy(entityType string, policy SensitivityPolicy) ConfigBuilder {
+	b.actions = append(b.actions, func(cfg *Config) {
+		if cfg.Sensitivity == nil {
+			cfg.Sensitivity = map[string]SensitivityPolicy{}
+		}
+		cfg.Sensitivity[entityType] = policy
+	})
+	return b
+}
+
 // NewConfigBuilder returns a fluent API to build the Config struct using the ConfigBuilder interface.
 func NewConfigBuilder() ConfigBuilder {
 	return &builder{
@@ -101,12 +340,39 @@ func NewConfigBuilder() ConfigBuilder {
 }
 
 // APIEndpoint is the Vertex AI API Endpoint, specific to the Region you have deployed
-// your feature store in.
+// your feature store in. Unlike ResolveEndpoint, it does not validate the Region.
 func (c *Config) APIEndpoint() string {
+	return c.endpoint(c.region())
+}
+
+// ResolveEndpoint validates the Region against AllowedRegions, or KnownRegions when it is
+// empty, and returns the HOST:PORT of the Vertex AI API: the Endpoint override, else the
+// EndpointTemplate, else the regional endpoint. The Client connects to this endpoint.
+func (c *Config) ResolveEndpoint() (string, error) {
+	region := c.region()
+	if err := validateRegion(region, c.AllowedRegions); err != nil {
+		return "", err
+	}
+	return c.endpoint(region), nil
+}
+
+// region returns the Region, falling back to the DefaultRegion.
+func (c *Config) region() string {
 	if c.Region == "" {
-		return buildEndpoint(DefaultRegion)
+		return DefaultRegion
+	}
+	return c.Region
+}
+
+// endpoint returns the endpoint of the Vertex AI API for region.
+func (c *Config) endpoint(region string) string {
+	switch {
+	case c.Endpoint != "":
+		return c.Endpoint
+	case c.EndpointTemplate != "":
+		return strings.NewReplacer("{region}", region, "{project}", c.ProjectID).Replace(c.EndpointTemplate)
 	}
-	return buildEndpoint(c.Region)
+	return buildEndpoint(region)
 }
 
 // ParentPath is the resource hierarchy for the feature store that we are interacting with.
@@ -64,3 +64,81 @@ func TestConfig_ParentPath(t *testing.T) {
 		t.Errorf("invalid ParentPath(): %v", cfg.ParentPath())
 	}
 }
+
+func TestConfig_ResolveEndpoint(t *testing.T) {
+	type test struct {
+		name     string
+		cfg      *Config
+		endpoint string
+		err      error
+	}
+
+	tests := []test{
+		{
+			name:     "regional endpoint",
+			cfg:      &Config{ProjectID: "my-project", Region: nane},
+			endpoint: "northamerica-northeast1-aiplatform.googleapis.com:443",
+		},
+		{
+			name:     "default region",
+			cfg:      &Config{ProjectID: "my-project"},
+			endpoint: "us-central1-aiplatform.googleapis.com:443",
+		},
+		{
+			name: "unknown region",
+			cfg:  &Config{ProjectID: "my-project", Region: "us-centrl1"},
+			err:  ErrInvalidRegion,
+		},
+		{
+			name:     "allowed regions",
+			cfg:      &Config{ProjectID: "my-project", Region: "mars-north1", AllowedRegions: []string{"mars-north1"}},
+			endpoint: "mars-north1-aiplatform.googleapis.com:443",
+		},
+		{
+			name: "region not allowed",
+			cfg:  &Config{ProjectID: "my-project", Region: nane, AllowedRegions: []string{"us-east1"}},
+			err:  ErrInvalidRegion,
+		},
+		{
+			name: "private service connect template",
+			cfg: &Config{
+				ProjectID:        "my-project",
+				Region:           "us-east1",
+				EndpointTemplate: "{region}-aiplatform-{project}.p.googleapis.com:443",
+			},
+			endpoint: "us-east1-aiplatform-my-project.p.googleapis.com:443",
+		},
+		{
+			name: "endpoint override",
+			cfg: &Config{
+				ProjectID:        "my-project",
+				Region:           "us-east1",
+				Endpoint:         "vertex.internal.example.com:443",
+				EndpointTemplate: "{region}-aiplatform-psc.p.googleapis.com:443",
+			},
+			endpoint: "vertex.internal.example.com:443",
+		},
+	}
+
+	for _, tc := range tests {
+		endpoint, err := tc.cfg.ResolveEndpoint()
+		if !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+			t.Errorf("%v: expected error %v, got %v", tc.name, tc.err, err)
+			continue
+		}
+		if endpoint != tc.endpoint {
+			t.Errorf("%v: expected %v, got %v", tc.name, tc.endpoint, endpoint)
+		}
+	}
+}
+
+func TestNewConfigBuilder_InvalidRegion(t *testing.T) {
+	_, err := NewConfigBuilder().
+		WithProjectID("my-project").
+		WithFeatureStoreName("my_featurestore").
+		WithRegion("us-centrl1").
+		Apply()
+	if !errors.Is(err, ErrInvalidRegion) {
+		t.Errorf("expected ErrInvalidRegion, got %v", err)
+	}
+}
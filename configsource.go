@@ -0,0 +1,184 @@
+package vertigo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is the prefix of the environment variables read by ConfigFromEnv when no prefix is
+// given, e.g. VERTIGO_PROJECT_ID.
+const EnvPrefix = "VERTIGO"
+
+// configSource loads a partial Config. origin names where the value of a Config key came from,
+// e.g. "file vertigo.yaml" or "env VERTIGO_REGION".
+type configSource struct {
+	load   func() (*Config, error)
+	origin func(key string) string
+
+	// env is set for the sources of FromEnv, which override the files of FromFile.
+	env bool
+}
+
+// LoadConfig reads a Config from a YAML (.yaml, .yml) or JSON file using the keys of the Config
+// struct tags, and validates it like ConfigBuilder.Apply. Unknown keys are an error.
+func LoadConfig(filename string) (*Config, error) {
+	return NewConfigBuilder().FromFile(filename).Apply()
+}
+
+// ConfigFromEnv reads a Config from the environment variables named after the Config keys with
+// prefix, e.g. VERTIGO_PROJECT_ID, VERTIGO_REGION and VERTIGO_FEATURE_STORE_NAME, and validates it
+// like ConfigBuilder.Apply. An empty prefix uses EnvPrefix.
+func ConfigFromEnv(prefix string) (*Config, error) {
+	return NewConfigBuilder().FromEnv(prefix).Apply()
+}
+
+// FromFile merges the Config read from a YAML or JSON file, as with LoadConfig.
+func (b *builder) FromFile(filename string) ConfigBuilder {
+	b.sources = append(b.sources, configSource{
+		load: func() (*Config, error) {
+			return readConfigFile(filename)
+		},
+		origin: func(string) string {
+			return "file " + filename
+		},
+	})
+	return b
+}
+
+// FromEnv merges the Config read from environment variables, as with ConfigFromEnv, over the
+// files of FromFile, whichever is called first. Unset and empty variables are ignored.
+func (b *builder) FromEnv(prefix string) ConfigBuilder {
+	if prefix == "" {
+		prefix = EnvPrefix
+	}
+	b.sources = append(b.sources, configSource{
+		load: func() (*Config, error) {
+			return readConfigEnv(prefix), nil
+		},
+		origin: func(key string) string {
+			return "env " + envName(prefix, key)
+		},
+		env: true,
+	})
+	return b
+}
+
+// readConfigFile decodes filename into a Config, rejecting unknown keys.
+func readConfigFile(filename string) (*Config, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("config file %v: %w", filename, err)
+	}
+	cfg := &Config{}
+	if isYAML(filename) {
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		err = dec.Decode(cfg)
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(cfg)
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("config file %v: %w", filename, err)
+	}
+	return cfg, nil
+}
+
+// readConfigEnv sets the string fields of a Config from the environment variables with prefix.
+func readConfigEnv(prefix string) *Config {
+	cfg := &Config{}
+	v := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.Type.Kind() != reflect.String || !field.IsExported() {
+			continue
+		}
+		if s := os.Getenv(envName(prefix, configKey(field))); s != "" {
+			v.Field(i).SetString(s)
+		}
+	}
+	return cfg
+}
+
+// envName returns the environment variable holding a Config key, e.g. VERTIGO_PROJECT_ID.
+func envName(prefix, key string) string {
+	return prefix + "_" + strings.ToUpper(key)
+}
+
+// configKey returns the key of a Config field in files, taken from its yaml tag.
+func configKey(field reflect.StructField) string {
+	return strings.Split(field.Tag.Get("yaml"), ",")[0]
+}
+
+// mergeConfig copies the non-zero fields of src into dst, recording origin(key) in origins for
+// each one. Maps are merged by key, recorded as "<key>.<map key>", e.g. "freshness.my_customer".
+func mergeConfig(dst, src *Config, origin func(key string) string, origins map[string]string) {
+	dv, sv := reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem()
+	for i := 0; i < sv.NumField(); i++ {
+		field := sv.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		key := configKey(field)
+		f := sv.Field(i)
+		switch {
+		case f.Kind() == reflect.Map:
+			if f.Len() == 0 {
+				continue
+			}
+			d := dv.Field(i)
+			if d.IsNil() {
+				d.Set(reflect.MakeMap(f.Type()))
+			}
+			iter := f.MapRange()
+			for iter.Next() {
+				d.SetMapIndex(iter.Key(), iter.Value())
+				k := fmt.Sprintf("%v.%v", key, iter.Key())
+				origins[k] = origin(k)
+			}
+		case !f.IsZero():
+			dv.Field(i).Set(f)
+			origins[key] = origin(key)
+		}
+	}
+}
+
+// jsonDuration decodes a time.Duration from JSON, either as a string accepted by
+// time.ParseDuration, e.g. "15m" as in YAML, or as integer nanoseconds.
+type jsonDuration time.Duration
+
+func (d *jsonDuration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		var n int64
+		if err := json.Unmarshal(b, &n); err != nil {
+			return fmt.Errorf("duration %s must be a string such as \"15m\" or integer nanoseconds", b)
+		}
+		*d = jsonDuration(n)
+		return nil
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = jsonDuration(parsed)
+	return nil
+}
+
+// decodeStrictJSON decodes b into v, rejecting unknown keys like readConfigFile, for the types
+// of a Config that implement json.Unmarshaler.
+func decodeStrictJSON(b []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
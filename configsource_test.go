@@ -0,0 +1,190 @@
+package vertigo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(filename, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestLoadConfig(t *testing.T) {
+	type test struct {
+		name    string
+		file    string
+		content string
+		err     error
+	}
+	tests := []test{
+		{
+			name: "yaml",
+			file: "vertigo.yaml",
+			content: `project_id: my-project
+feature_store_name: my_featurestore
+freshness:
+  my_customer:
+    max_age: 1h
+    features:
+      segment: 15m
+    action: drop
+failover:
+  latency_budget: 150ms
+  cooldown: 1m
+`,
+		},
+		{
+			name: "json",
+			file: "vertigo.json",
+			content: `{"project_id": "my-project", "feature_store_name": "my_featurestore",
+"freshness": {"my_customer": {"max_age": "1h", "features": {"segment": "15m"}, "action": "drop"}},
+"failover": {"latency_budget": "150ms", "cooldown": "1m"}}`,
+		},
+		{
+			name: "json nanoseconds",
+			file: "vertigo.json",
+			content: `{"project_id": "my-project", "feature_store_name": "my_featurestore",
+"freshness": {"my_customer": {"max_age": 3600000000000, "features": {"segment": 900000000000}, "action": "drop"}},
+"failover": {"latency_budget": 150000000, "cooldown": 60000000000}}`,
+		},
+		{
+			name:    "missing feature store name",
+			file:    "vertigo.yaml",
+			content: "project_id: my-project\n",
+			err:     ErrInvalidFeatureStoreName,
+		},
+	}
+	for _, tc := range tests {
+		cfg, err := LoadConfig(writeConfigFile(t, tc.file, tc.content))
+		if tc.err != nil {
+			if !errors.Is(err, tc.err) {
+				t.Errorf("%v: expected %v, got %v", tc.name, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", tc.name, err)
+			continue
+		}
+		if cfg.ProjectID != "my-project" || cfg.FeatureStoreName != "my_featurestore" || cfg.Region != DefaultRegion ||
+			cfg.Freshness["my_customer"].MaxAge != time.Hour || cfg.Freshness["my_customer"].Action != StaleDrop ||
+			cfg.Freshness["my_customer"].Features["segment"] != 15*time.Minute ||
+			cfg.Failover.LatencyBudget != 150*time.Millisecond || cfg.Failover.Cooldown != time.Minute {
+			t.Errorf("%v: config was not loaded correctly: %+v", tc.name, cfg)
+		}
+	}
+}
+
+func TestLoadConfig_Errors(t *testing.T) {
+	type test struct {
+		name     string
+		file     string
+		content  string
+		contains string
+	}
+	tests := []test{
+		{
+			name:     "unknown key",
+			file:     "vertigo.yaml",
+			content:  "project_id: my-project\nfeaturestore: my_featurestore\n",
+			contains: "featurestore",
+		},
+		{
+			name: "bad value names the file",
+			file: "vertigo.yaml",
+			content: `project_id: my-project
+feature_store_name: my_featurestore
+freshness:
+  my_customer:
+    max_age: -1h
+`,
+			contains: "(from file ",
+		},
+		{
+			name: "unknown stale action",
+			file: "vertigo.yaml",
+			content: `project_id: my-project
+feature_store_name: my_featurestore
+freshness:
+  my_customer:
+    action: ignore
+`,
+			contains: "ignore",
+		},
+		{
+			name:     "json bad duration",
+			file:     "vertigo.json",
+			content:  `{"project_id": "my-project", "feature_store_name": "my_featurestore", "freshness": {"my_customer": {"max_age": "an hour"}}}`,
+			contains: "an hour",
+		},
+		{
+			name:     "json unknown policy key",
+			file:     "vertigo.json",
+			content:  `{"project_id": "my-project", "feature_store_name": "my_featurestore", "failover": {"budget": "1s"}}`,
+			contains: "budget",
+		},
+	}
+	for _, tc := range tests {
+		filename := writeConfigFile(t, tc.file, tc.content)
+		_, err := LoadConfig(filename)
+		if err == nil || !strings.Contains(err.Error(), tc.contains) {
+			t.Errorf("%v: expected an error containing %q, got %v", tc.name, tc.contains, err)
+		}
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("VERTIGO_PROJECT_ID", "my-project")
+	t.Setenv("VERTIGO_REGION", nane)
+	t.Setenv("VERTIGO_FEATURE_STORE_NAME", "my_featurestore")
+	t.Setenv("OTHER_PROJECT_ID", "other-project")
+
+	cfg, err := ConfigFromEnv("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ProjectID != "my-project" || cfg.Region != nane || cfg.FeatureStoreName != "my_featurestore" {
+		t.Errorf("config was not read from the environment: %+v", cfg)
+	}
+
+	if _, err := ConfigFromEnv("OTHER"); !errors.Is(err, ErrInvalidFeatureStoreName) {
+		t.Errorf("expected ErrInvalidFeatureStoreName, got %v", err)
+	}
+}
+
+func TestConfigBuilder_MergeOrder(t *testing.T) {
+	filename := writeConfigFile(t, "vertigo.yaml", `project_id: file-project
+region: us-east1
+feature_store_name: file_featurestore
+`)
+	t.Setenv("VERTIGO_REGION", nane)
+
+	type test struct {
+		name    string
+		builder ConfigBuilder
+	}
+	tests := []test{
+		{name: "file then env", builder: NewConfigBuilder().WithFeatureStoreName("my_featurestore").FromFile(filename).FromEnv("")},
+		{name: "env then file", builder: NewConfigBuilder().FromEnv("").FromFile(filename).WithFeatureStoreName("my_featurestore")},
+	}
+	for _, tc := range tests {
+		cfg, err := tc.builder.Apply()
+		if err != nil {
+			t.Fatalf("%v: %v", tc.name, err)
+		}
+		// the file overrides the default region and the environment overrides the file, while
+		// the builder overrides every source regardless of the order of the calls
+		if cfg.ProjectID != "file-project" || cfg.Region != nane || cfg.FeatureStoreName != "my_featurestore" {
+			t.Errorf("%v: sources were not merged in order: %+v", tc.name, cfg)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package vertigo
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// DiffKind classifies a difference between the features of two entities.
+type DiffKind int
+
+const (
+	// OnlyInA is a feature that only has a value in the first entity.
+	OnlyInA DiffKind = iota
+	// OnlyInB is a feature that only has a value in the second entity.
+	OnlyInB
+	// TypeDiffers is a feature whose value type differs.
+	TypeDiffers
+	// ValueDiffers is a feature whose value differs. For arrays, Detail describes the first
+	// differing element or the change of length.
+	ValueDiffers
+	// GenerateTimeDiffers is a feature whose value was generated at a different time.
+	GenerateTimeDiffers
+)
+
+var diffKindNames = map[DiffKind]string{
+	OnlyInA:             "only in a",
+	OnlyInB:             "only in b",
+	TypeDiffers:         "type differs",
+	ValueDiffers:        "value differs",
+	GenerateTimeDiffers: "generate time differs",
+}
+
+func (k DiffKind) String() string {
+	return diffKindNames[k]
+}
+
+// FeatureDiff is a single difference between the features of two entities.
+type FeatureDiff struct {
+	Kind      DiffKind
+	FeatureID string
+
+	// A and B are the values of the feature, the zero Value when it has none.
+	A Value
+	B Value
+
+	// Detail locates the difference within an array value, e.g. "element 2: 1.5 -> 1.7".
+	Detail string
+
+	// Sensitive is set when the feature is sensitive in either entity. Its values are then
+	// redacted from String, and Detail is left empty.
+	Sensitive bool
+}
+
+func (d FeatureDiff) String() string {
+	a, b := d.A.String(), d.B.String()
+	if d.Sensitive {
+		a, b = redacted, redacted
+	}
+	switch d.Kind {
+	case OnlyInA:
+		return fmt.Sprintf("- %v (%v) = %v", d.FeatureID, d.A.Type(), a)
+	case OnlyInB:
+		return fmt.Sprintf("+ %v (%v) = %v", d.FeatureID, d.B.Type(), b)
+	case TypeDiffers:
+		return fmt.Sprintf("~ %v: %v -> %v", d.FeatureID, d.A.Type(), d.B.Type())
+	case GenerateTimeDiffers:
+		return fmt.Sprintf("~ %v: generate time %v -> %v", d.FeatureID, d.A.GenerateTime(), d.B.GenerateTime())
+	}
+	if d.Detail != "" {
+		return fmt.Sprintf("~ %v: %v", d.FeatureID, d.Detail)
+	}
+	return fmt.Sprintf("~ %v: %v -> %v", d.FeatureID, a, b)
+}
+
+// FeatureDiffs is the list of differences between two entities, sorted by feature ID.
+type FeatureDiffs []FeatureDiff
+
+// FeatureIDs returns the sorted IDs of the features that differ, each listed once.
+func (ds FeatureDiffs) FeatureIDs() []string {
+	var ids []string
+	for i, d := range ds {
+		if i == 0 || ds[i-1].FeatureID != d.FeatureID {
+			ids = append(ids, d.FeatureID)
+		}
+	}
+	return ids
+}
+
+// String renders a report with one difference per line, or "no differences".
+func (ds FeatureDiffs) String() string {
+	if len(ds) == 0 {
+		return "no differences"
+	}
+	lines := make([]string, 0, len(ds))
+	for _, d := range ds {
+		lines = append(lines, d.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// DiffOption configures how Diff compares features.
+type DiffOption func(*diffOptions)
+
+type diffOptions struct {
+	tolerance          float64
+	ignoreGenerateTime bool
+}
+
+// FloatTolerance makes DOUBLE and DOUBLE_ARRAY values equal when they differ by at most tolerance.
+func FloatTolerance(tolerance float64) DiffOption {
+	return func(o *diffOptions) {
+		o.tolerance = tolerance
+	}
+}
+
+// IgnoreGenerateTime skips the comparison of generate times, e.g. between stores that ingested
+// the same values at different times.
+func IgnoreGenerateTime() DiffOption {
+	return func(o *diffOptions) {
+		o.ignoreGenerateTime = true
+	}
+}
+
+// Diff compares the features of a and b: their presence, value type, value, including the
+// elements of arrays, and generate time. Features without a value are treated as absent. The
+// differences are sorted by feature ID, and the values of sensitive features are redacted from
+// their String.
+func Diff(a, b *Entity, opts ...DiffOption) FeatureDiffs {
+	o := &diffOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	seen := map[string]bool{}
+	var ids []string
+	for _, id := range append(a.FeatureIDs(), b.FeatureIDs()...) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	var diffs FeatureDiffs
+	for _, id := range ids {
+		va, okA := a.Value(id)
+		vb, okB := b.Value(id)
+		d := FeatureDiff{FeatureID: id, A: va, B: vb, Sensitive: a.Sensitive(id) || b.Sensitive(id)}
+		switch {
+		case !okA && !okB:
+			continue
+		case !okB:
+			d.Kind = OnlyInA
+		case !okA:
+			d.Kind = OnlyInB
+		case va.Type() != vb.Type():
+			d.Kind = TypeDiffers
+		default:
+			if detail, equal := compareValues(va, vb, o.tolerance); !equal {
+				d.Kind, d.Detail = ValueDiffers, detail
+				if d.Sensitive {
+					d.Detail = ""
+				}
+				diffs = append(diffs, d)
+			}
+			if !o.ignoreGenerateTime && !va.GenerateTime().Equal(vb.GenerateTime()) {
+				d.Kind, d.Detail = GenerateTimeDiffers, ""
+				diffs = append(diffs, d)
+			}
+			continue
+		}
+		diffs = append(diffs, d)
+	}
+	return diffs
+}
+
+// compareValues reports whether two values of the same type are equal, and for arrays describes
+// where they differ.
+func compareValues(a, b Value, tolerance float64) (string, bool) {
+	switch x := a.Interface().(type) {
+	case float64:
+		y, _ := b.AsFloat64()
+		return "", floatEqual(x, y, tolerance)
+	case []float64:
+		y, _ := b.AsFloat64Slice()
+		if len(x) != len(y) {
+			return fmt.Sprintf("length %v -> %v", len(x), len(y)), false
+		}
+		for i := range x {
+			if !floatEqual(x[i], y[i], tolerance) {
+				return fmt.Sprintf("element %v: %v -> %v", i, x[i], y[i]), false
+			}
+		}
+		return "", true
+	}
+
+	av, bv := reflect.ValueOf(a.Interface()), reflect.ValueOf(b.Interface())
+	if av.Kind() != reflect.Slice || av.Type() == reflect.TypeOf([]byte(nil)) {
+		return "", reflect.DeepEqual(a.Interface(), b.Interface())
+	}
+	if av.Len() != bv.Len() {
+		return fmt.Sprintf("length %v -> %v", av.Len(), bv.Len()), false
+	}
+	for i := 0; i < av.Len(); i++ {
+		if x, y := av.Index(i).Interface(), bv.Index(i).Interface(); x != y {
+			return fmt.Sprintf("element %v: %v -> %v", i, x, y), false
+		}
+	}
+	return "", true
+}
+
+// floatEqual reports whether x and y differ by at most tolerance. NaN equals NaN.
+func floatEqual(x, y, tolerance float64) bool {
+	if math.IsNaN(x) || math.IsNaN(y) {
+		return math.IsNaN(x) && math.IsNaN(y)
+	}
+	return x == y || math.Abs(x-y) <= tolerance
+}
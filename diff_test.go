@@ -0,0 +1,81 @@
+package vertigo
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+)
+
+func doubleFeature(f float64) *aiplatformpb.FeatureValue {
+	return &aiplatformpb.FeatureValue{Value: &aiplatformpb.FeatureValue_DoubleValue{DoubleValue: f}}
+}
+
+func TestDiff(t *testing.T) {
+	generated := time.Date(2023, 2, 16, 12, 0, 0, 0, time.UTC)
+	a := newTestEntity(map[string]*aiplatformpb.FeatureValue{
+		"segment":         withGenerateTime(stringFeature("gold"), generated),
+		"six_month_spend": doubleFeature(150),
+		"score":           doubleFeature(math.NaN()),
+		"audiences": {Value: &aiplatformpb.FeatureValue_StringArrayValue{
+			StringArrayValue: &aiplatformpb.StringArray{Values: []string{"a", "b", "c"}},
+		}},
+		"weights": {Value: &aiplatformpb.FeatureValue_DoubleArrayValue{
+			DoubleArrayValue: &aiplatformpb.DoubleArray{Values: []float64{0.1, 0.2}},
+		}},
+		"visits":  {Value: &aiplatformpb.FeatureValue_Int64Value{Int64Value: 12}},
+		"churned": nil,
+		"removed": stringFeature("x"),
+	})
+	b := newTestEntity(map[string]*aiplatformpb.FeatureValue{
+		"segment":         withGenerateTime(stringFeature("gold"), generated.Add(time.Hour)),
+		"six_month_spend": doubleFeature(150.0000001),
+		"score":           doubleFeature(math.NaN()),
+		"audiences": {Value: &aiplatformpb.FeatureValue_StringArrayValue{
+			StringArrayValue: &aiplatformpb.StringArray{Values: []string{"a", "x", "c"}},
+		}},
+		"weights": {Value: &aiplatformpb.FeatureValue_DoubleArrayValue{
+			DoubleArrayValue: &aiplatformpb.DoubleArray{Values: []float64{0.1, 0.2, 0.3}},
+		}},
+		"visits": doubleFeature(12),
+		"added":  stringFeature("y"),
+	})
+
+	type test struct {
+		name     string
+		opts     []DiffOption
+		expected string
+	}
+	tests := []test{
+		{
+			name: "exact",
+			expected: `+ added (STRING) = y
+~ audiences: element 1: b -> x
+- removed (STRING) = x
+~ segment: generate time 2023-02-16 12:00:00 +0000 UTC -> 2023-02-16 13:00:00 +0000 UTC
+~ six_month_spend: 150 -> 150.0000001
+~ visits: INT64 -> DOUBLE
+~ weights: length 2 -> 3`,
+		},
+		{
+			name: "tolerance and no generate times",
+			opts: []DiffOption{FloatTolerance(1e-6), IgnoreGenerateTime()},
+			expected: `+ added (STRING) = y
+~ audiences: element 1: b -> x
+- removed (STRING) = x
+~ visits: INT64 -> DOUBLE
+~ weights: length 2 -> 3`,
+		},
+	}
+	for _, tc := range tests {
+		diffs := Diff(a, b, tc.opts...)
+		if diffs.String() != tc.expected {
+			t.Errorf("%v: expected\n%v\ngot\n%v", tc.name, tc.expected, diffs)
+		}
+	}
+
+	if diffs := Diff(a, a); len(diffs) != 0 || diffs.String() != "no differences" {
+		t.Errorf("expected an entity to equal itself, got %v", diffs)
+	}
+}
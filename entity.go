@@ -0,0 +1,164 @@
+package vertigo
+
+import (
+	"time"
+
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+	"google.golang.org/protobuf/proto"
+)
+
+// GenerateTime returns the time the value of featureID was generated, as reported by the
+// feature store. The boolean is false when the feature is missing from the Entity, has no
+// value, or carries no generate time.
+func (e *Entity) GenerateTime(featureID string) (time.Time, bool) {
+	fv, ok := e.featureValue(featureID)
+	if !ok {
+		return time.Time{}, false
+	}
+	ts := fv.GetMetadata().GetGenerateTime()
+	if ts == nil {
+		return time.Time{}, false
+	}
+	return ts.AsTime(), true
+}
+
+// GenerateTimes returns the generate time of every feature in the Entity that has one,
+// keyed by feature ID.
+func (e *Entity) GenerateTimes() map[string]time.Time {
+	times := map[string]time.Time{}
+	for i, fd := range e.header.GetFeatureDescriptors() {
+		if i >= len(e.data) {
+			break
+		}
+		ts := e.data[i].GetValue().GetMetadata().GetGenerateTime()
+		if ts == nil {
+			continue
+		}
+		times[fd.Id] = ts.AsTime()
+	}
+	return times
+}
+
+// setValue replaces the value of featureID, or adds the feature when the Entity does not have
+// it. The header is copied before a feature is added, as entities read by GetEntities share it.
+// Missing values are padded when the Entity has fewer values than feature descriptors.
+func (e *Entity) setValue(featureID string, v Value) {
+	data := &aiplatformpb.ReadFeatureValuesResponse_EntityView_Data{}
+	if fv := v.toProto(); fv != nil {
+		data.Data = &aiplatformpb.ReadFeatureValuesResponse_EntityView_Data_Value{Value: fv}
+	}
+
+	descriptors := e.header.GetFeatureDescriptors()
+	if len(e.data) < len(descriptors) {
+		e.data = append(e.data, make([]*aiplatformpb.ReadFeatureValuesResponse_EntityView_Data, len(descriptors)-len(e.data))...)
+	}
+	for i, fd := range descriptors {
+		if fd.Id == featureID {
+			e.data[i] = data
+			return
+		}
+	}
+
+	header := &aiplatformpb.ReadFeatureValuesResponse_Header{}
+	if e.header != nil {
+		header = proto.Clone(e.header).(*aiplatformpb.ReadFeatureValuesResponse_Header)
+	}
+	header.FeatureDescriptors = append(
+		header.FeatureDescriptors,
+		&aiplatformpb.ReadFeatureValuesResponse_FeatureDescriptor{Id: featureID},
+	)
+	e.header = header
+	e.data = append(e.data, data)
+}
+
+// featureValue looks up the value of featureID. The boolean is false when the feature is
+// not part of the Entity or has no value.
+func (e *Entity) featureValue(featureID string) (*aiplatformpb.FeatureValue, bool) {
+	for i, fd := range e.header.GetFeatureDescriptors() {
+		if fd.Id != featureID || i >= len(e.data) {
+			continue
+		}
+		fv := e.data[i].GetValue()
+		return fv, fv != nil
+	}
+	return nil, false
+}
+
+// FeatureIDs returns the ID of every feature in the Entity, in the order returned by the
+// feature store. Features without a value are included; use Has to tell them apart.
+func (e *Entity) FeatureIDs() []string {
+	ids := make([]string, 0, len(e.header.GetFeatureDescriptors()))
+	for _, fd := range e.header.GetFeatureDescriptors() {
+		ids = append(ids, fd.Id)
+	}
+	return ids
+}
+
+// Has reports whether the Entity holds a value for featureID.
+func (e *Entity) Has(featureID string) bool {
+	_, ok := e.featureValue(featureID)
+	return ok
+}
+
+// Value returns the value of featureID. The boolean is false when the feature has no value.
+func (e *Entity) Value(featureID string) (Value, bool) {
+	fv, ok := e.featureValue(featureID)
+	if !ok {
+		return Value{}, false
+	}
+	return valueFromProto(fv), true
+}
+
+// Bool returns the value of a BOOL feature.
+func (e *Entity) Bool(featureID string) (bool, bool) {
+	v, _ := e.Value(featureID)
+	return v.AsBool()
+}
+
+// Int64 returns the value of an INT64 feature.
+func (e *Entity) Int64(featureID string) (int64, bool) {
+	v, _ := e.Value(featureID)
+	return v.AsInt64()
+}
+
+// Float64 returns the value of a DOUBLE feature.
+func (e *Entity) Float64(featureID string) (float64, bool) {
+	v, _ := e.Value(featureID)
+	return v.AsFloat64()
+}
+
+// String returns the value of a STRING feature.
+func (e *Entity) String(featureID string) (string, bool) {
+	v, _ := e.Value(featureID)
+	return v.AsString()
+}
+
+// Bytes returns the value of a BYTES feature.
+func (e *Entity) Bytes(featureID string) ([]byte, bool) {
+	v, _ := e.Value(featureID)
+	return v.AsBytes()
+}
+
+// BoolSlice returns the value of a BOOL_ARRAY feature.
+func (e *Entity) BoolSlice(featureID string) ([]bool, bool) {
+	v, _ := e.Value(featureID)
+	return v.AsBoolSlice()
+}
+
+// Int64Slice returns the value of an INT64_ARRAY feature.
+func (e *Entity) Int64Slice(featureID string) ([]int64, bool) {
+	v, _ := e.Value(featureID)
+	return v.AsInt64Slice()
+}
+
+// Float64Slice returns the value of a DOUBLE_ARRAY feature.
+func (e *Entity) Float64Slice(featureID string) ([]float64, bool) {
+	v, _ := e.Value(featureID)
+	return v.AsFloat64Slice()
+}
+
+// StringSlice returns the value of a STRING_ARRAY feature.
+func (e *Entity) StringSlice(featureID string) ([]string, bool) {
+	v, _ := e.Value(featureID)
+	return v.AsStringSlice()
+}
@@ -0,0 +1,115 @@
+package vertigo
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// withGenerateTime attaches generate time metadata to fv.
+func withGenerateTime(fv *aiplatformpb.FeatureValue, t time.Time) *aiplatformpb.FeatureValue {
+	fv.Metadata = &aiplatformpb.FeatureValue_Metadata{GenerateTime: timestamppb.New(t)}
+	return fv
+}
+
+func TestEntity_GenerateTime(t *testing.T) {
+	generated := time.Date(2023, 2, 16, 12, 0, 0, 0, time.UTC)
+	entity := newTestEntity(map[string]*aiplatformpb.FeatureValue{
+		"segment":  withGenerateTime(stringFeature("gold"), generated),
+		"no_time":  stringFeature("silver"),
+		"no_value": nil,
+	})
+
+	type test struct {
+		featureID string
+		want      time.Time
+		ok        bool
+	}
+
+	tests := []test{
+		{featureID: "segment", want: generated, ok: true},
+		{featureID: "no_time", ok: false},
+		{featureID: "no_value", ok: false},
+		{featureID: "missing", ok: false},
+	}
+
+	for _, tc := range tests {
+		got, ok := entity.GenerateTime(tc.featureID)
+		if ok != tc.ok || !got.Equal(tc.want) {
+			t.Errorf("%v: expected (%v, %v), got (%v, %v)", tc.featureID, tc.want, tc.ok, got, ok)
+		}
+	}
+
+	times := entity.GenerateTimes()
+	if len(times) != 1 || !times["segment"].Equal(generated) {
+		t.Errorf("unexpected GenerateTimes(): %v", times)
+	}
+
+	entity.data = entity.data[:1]
+	if times := entity.GenerateTimes(); len(times) != 0 {
+		t.Errorf("expected no generate times for a short response, got %v", times)
+	}
+}
+
+func TestEntity_ScanStructTimestamp(t *testing.T) {
+	type withTimes struct {
+		Segment        string     `vertex:"segment"`
+		SegmentTime    time.Time  `vertex:"segment,timestamp"`
+		SegmentTimePtr *time.Time `vertex:"segment,timestamp"`
+	}
+	generated := time.Date(2023, 2, 16, 12, 0, 0, 0, time.UTC)
+	entity := newTestEntity(map[string]*aiplatformpb.FeatureValue{
+		"segment": withGenerateTime(stringFeature("gold"), generated),
+	})
+
+	w := withTimes{}
+	if err := entity.ScanStruct(&w); err != nil {
+		t.Fatal(err)
+	}
+	if w.Segment != "gold" || !w.SegmentTime.Equal(generated) || w.SegmentTimePtr == nil || !w.SegmentTimePtr.Equal(generated) {
+		t.Errorf("timestamp was not scanned: %+v", w)
+	}
+
+	bad := struct {
+		SegmentTime string `vertex:"segment,timestamp"`
+	}{}
+	if err := entity.ScanStruct(&bad); err == nil {
+		t.Error("expected an error scanning a timestamp into a string field")
+	}
+}
+
+func TestEntity_Accessors(t *testing.T) {
+	entity := newTestEntity(map[string]*aiplatformpb.FeatureValue{
+		"segment": stringFeature("gold"),
+		"spend":   {Value: &aiplatformpb.FeatureValue_DoubleValue{DoubleValue: 12.5}},
+		"visits":  {Value: &aiplatformpb.FeatureValue_Int64Value{Int64Value: 3}},
+		"empty":   nil,
+	})
+
+	if ids := entity.FeatureIDs(); len(ids) != 4 {
+		t.Errorf("expected 4 feature IDs, got %v", ids)
+	}
+	if !entity.Has("segment") || entity.Has("empty") || entity.Has("missing") {
+		t.Error("Has reported the wrong presence")
+	}
+	if s, ok := entity.String("segment"); !ok || s != "gold" {
+		t.Errorf("String: got %v, %v", s, ok)
+	}
+	if f, ok := entity.Float64("spend"); !ok || f != 12.5 {
+		t.Errorf("Float64: got %v, %v", f, ok)
+	}
+	if i, ok := entity.Int64("visits"); !ok || i != 3 {
+		t.Errorf("Int64: got %v, %v", i, ok)
+	}
+	if _, ok := entity.Int64("spend"); ok {
+		t.Error("Int64 should not convert a DOUBLE feature")
+	}
+	if v, ok := entity.Value("segment"); !ok || v.Type() != StringType || v.Interface() != "gold" {
+		t.Errorf("Value: got %v, %v", v, ok)
+	}
+	if _, ok := entity.Value("empty"); ok {
+		t.Error("Value should report a feature without a value as missing")
+	}
+}
@@ -0,0 +1,270 @@
+package vertigo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	aiplatform "cloud.google.com/go/aiplatform/apiv1beta1"
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// MetricFailovers counts reads that failed over from a featurestore to the next replica.
+const MetricFailovers = "failovers"
+
+// DefaultFailoverCooldown is how long a featurestore that failed a read is skipped when the
+// FailoverPolicy does not set a Cooldown.
+const DefaultFailoverCooldown = 30 * time.Second
+
+var ErrInvalidFailoverPolicy = errors.New("failover policy is not valid")
+
+// Replica is a copy of the featurestore of a Config, usually in another region. Region is
+// required; the other empty fields default to the ones of the Config, except Endpoint, which
+// only applies to the featurestore it is set on.
+type Replica struct {
+	ProjectID        string `json:"project_id,omitempty" yaml:"project_id,omitempty"`
+	Region           string `json:"region" yaml:"region"`
+	FeatureStoreName string `json:"feature_store_name,omitempty" yaml:"feature_store_name,omitempty"`
+	Endpoint         string `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+}
+
+// FailoverPolicy controls when reads fail over to the Replicas of a Config. A read fails over
+// when a featurestore returns UNAVAILABLE or does not answer within the LatencyBudget. The
+// featurestore is then skipped for the Cooldown, after which reads fail back to it.
+type FailoverPolicy struct {
+	// LatencyBudget is the timeout of a read from every featurestore but the last one tried.
+	// Streaming reads of GetEntities only need their first response within it. Zero disables
+	// it, so only UNAVAILABLE errors fail over.
+	LatencyBudget time.Duration `json:"latency_budget,omitempty" yaml:"latency_budget,omitempty"`
+
+	// Cooldown defaults to DefaultFailoverCooldown.
+	Cooldown time.Duration `json:"cooldown,omitempty" yaml:"cooldown,omitempty"`
+}
+
+// UnmarshalJSON decodes the policy, accepting durations such as "150ms" as well as integer
+// nanoseconds.
+func (p *FailoverPolicy) UnmarshalJSON(b []byte) error {
+	in := struct {
+		LatencyBudget jsonDuration `json:"latency_budget,omitempty"`
+		Cooldown      jsonDuration `json:"cooldown,omitempty"`
+	}{LatencyBudget: jsonDuration(p.LatencyBudget), Cooldown: jsonDuration(p.Cooldown)}
+	if err := decodeStrictJSON(b, &in); err != nil {
+		return err
+	}
+	p.LatencyBudget, p.Cooldown = time.Duration(in.LatencyBudget), time.Duration(in.Cooldown)
+	return nil
+}
+
+// validate checks that the durations of the policy are not negative.
+func (p FailoverPolicy) validate() error {
+	if p.LatencyBudget < 0 || p.Cooldown < 0 {
+		return fmt.Errorf("%w: durations must not be negative", ErrInvalidFailoverPolicy)
+	}
+	return nil
+}
+
+// cooldown returns the Cooldown, falling back to DefaultFailoverCooldown.
+func (p FailoverPolicy) cooldown() time.Duration {
+	if p.Cooldown == 0 {
+		return DefaultFailoverCooldown
+	}
+	return p.Cooldown
+}
+
+// replicaConfig returns the Config reading from replica r.
+func (c *Config) replicaConfig(r Replica) *Config {
+	cfg := *c
+	cfg.Replicas = nil
+	cfg.Region = r.Region
+	cfg.Endpoint = r.Endpoint
+	if r.ProjectID != "" {
+		cfg.ProjectID = r.ProjectID
+	}
+	if r.FeatureStoreName != "" {
+		cfg.FeatureStoreName = r.FeatureStoreName
+	}
+	return &cfg
+}
+
+// target is a featurestore the Client reads from: the one of its Config or a replica.
+type target struct {
+	cfg    *Config
+	read   func(ctx context.Context, req *aiplatformpb.ReadFeatureValuesRequest) (*aiplatformpb.ReadFeatureValuesResponse, error)
+	stream func(ctx context.Context, req *aiplatformpb.StreamingReadFeatureValuesRequest) (featureValuesStream, error)
+
+	// unhealthyUntil is when the target is tried first again after a failed read.
+	unhealthyUntil time.Time
+}
+
+// newTarget returns a target reading from cfg through the serving client v.
+func newTarget(cfg *Config, v *aiplatform.FeaturestoreOnlineServingClient) *target {
+	return &target{
+		cfg: cfg,
+		read: func(ctx context.Context, req *aiplatformpb.ReadFeatureValuesRequest) (*aiplatformpb.ReadFeatureValuesResponse, error) {
+			return v.ReadFeatureValues(ctx, req)
+		},
+		stream: func(ctx context.Context, req *aiplatformpb.StreamingReadFeatureValuesRequest) (featureValuesStream, error) {
+			return v.StreamingReadFeatureValues(ctx, req)
+		},
+	}
+}
+
+// ReplicaHealth is the health of one of the featurestores of a Client, as tracked for failover.
+type ReplicaHealth struct {
+	Region           string
+	FeatureStoreName string
+	Healthy          bool
+
+	// UnhealthyUntil is when reads fail back to the featurestore, if it is not Healthy.
+	UnhealthyUntil time.Time
+}
+
+// failoverState tracks the health of the featurestores a Client fails over between.
+type failoverState struct {
+	mu      sync.Mutex
+	targets []*target
+}
+
+// Health returns the health of the featurestore of the Config followed by its replicas.
+func (c *Client) Health() []ReplicaHealth {
+	c.failover.mu.Lock()
+	defer c.failover.mu.Unlock()
+	now := c.now()
+	health := make([]ReplicaHealth, 0, len(c.failover.targets))
+	for _, t := range c.failover.targets {
+		h := ReplicaHealth{
+			Region:           t.cfg.Region,
+			FeatureStoreName: t.cfg.FeatureStoreName,
+			Healthy:          !now.Before(t.unhealthyUntil),
+		}
+		if !h.Healthy {
+			h.UnhealthyUntil = t.unhealthyUntil
+		}
+		health = append(health, h)
+	}
+	return health
+}
+
+// withFailover runs call against each featurestore in order until one succeeds or fails with an
+// error that does not warrant a failover. Healthy featurestores are tried first, in the order of
+// the Config, so reads fail back to the primary once its cooldown expires.
+// The latency budget of a featurestore is passed to call, which may stop it once the
+// featurestore responded.
+func (c *Client) withFailover(
+	ctx context.Context,
+	entityType string,
+	call func(ctx context.Context, t *target, budget *latencyBudget) error,
+) error {
+	targets := c.targetOrder()
+	policy := c.cfg.Failover
+
+	var err error
+	for i, t := range targets {
+		last := i == len(targets)-1
+		callCtx, cancel := context.WithCancel(ctx)
+		var budget *latencyBudget
+		if policy.LatencyBudget > 0 && !last {
+			budget = startLatencyBudget(policy.LatencyBudget, cancel)
+		}
+		err = call(callCtx, t, budget)
+		budget.stop()
+		cancel()
+
+		if err == nil || !(shouldFailover(ctx, err) || (ctx.Err() == nil && budget.exceeded())) {
+			if err == nil {
+				c.setUnhealthyUntil(t, time.Time{})
+			}
+			return err
+		}
+		c.setUnhealthyUntil(t, c.now().Add(policy.cooldown()))
+		if !last {
+			c.metrics.Inc(MetricFailovers, entityType, "")
+		}
+	}
+	return err
+}
+
+// latencyBudget cancels a call that does not respond within the LatencyBudget of the
+// FailoverPolicy.
+type latencyBudget struct {
+	timer   *time.Timer
+	expired int32
+}
+
+// startLatencyBudget returns a latencyBudget calling cancel after d.
+func startLatencyBudget(d time.Duration, cancel context.CancelFunc) *latencyBudget {
+	b := &latencyBudget{}
+	b.timer = time.AfterFunc(d, func() {
+		atomic.StoreInt32(&b.expired, 1)
+		cancel()
+	})
+	return b
+}
+
+// stop stops the budget, e.g. once a stream sent its first response. A nil budget is never
+// exceeded.
+func (b *latencyBudget) stop() {
+	if b != nil {
+		b.timer.Stop()
+	}
+}
+
+// exceeded reports whether the budget cancelled the call.
+func (b *latencyBudget) exceeded() bool {
+	return b != nil && atomic.LoadInt32(&b.expired) == 1
+}
+
+// budgetedStream stops the latency budget of a streaming read once the first response arrives,
+// so the budget does not limit how long the rest of the stream takes.
+type budgetedStream struct {
+	featureValuesStream
+	budget *latencyBudget
+}
+
+func (s *budgetedStream) Recv() (*aiplatformpb.ReadFeatureValuesResponse, error) {
+	res, err := s.featureValuesStream.Recv()
+	s.budget.stop()
+	return res, err
+}
+
+// targetOrder returns the healthy featurestores followed by the unhealthy ones, each in the
+// order of the Config.
+func (c *Client) targetOrder() []*target {
+	c.failover.mu.Lock()
+	defer c.failover.mu.Unlock()
+	now := c.now()
+	healthy := make([]*target, 0, len(c.failover.targets))
+	var unhealthy []*target
+	for _, t := range c.failover.targets {
+		if now.Before(t.unhealthyUntil) {
+			unhealthy = append(unhealthy, t)
+		} else {
+			healthy = append(healthy, t)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+func (c *Client) setUnhealthyUntil(t *target, until time.Time) {
+	c.failover.mu.Lock()
+	defer c.failover.mu.Unlock()
+	t.unhealthyUntil = until
+}
+
+// shouldFailover reports whether err is an UNAVAILABLE error or a timeout caused by the latency
+// budget rather than by ctx, the context of the caller.
+func shouldFailover(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,260 @@
+package vertigo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	aiplatform "cloud.google.com/go/aiplatform/apiv1beta1"
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newTestFailoverClient creates a Client for cfg whose featurestores answer with the read
+// function registered for their region.
+func newTestFailoverClient(
+	t *testing.T,
+	cfg *Config,
+	reads map[string]func(ctx context.Context) error,
+	opts ...ClientOption,
+) *Client {
+	t.Helper()
+	dial := func(ctx context.Context, endpoint string) (*aiplatform.FeaturestoreOnlineServingClient, error) {
+		return aiplatform.NewFeaturestoreOnlineServingClient(ctx, option.WithEndpoint(endpoint), option.WithoutAuthentication())
+	}
+	c, err := newClient(context.Background(), cfg, dial, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tgt := range c.failover.targets {
+		read := reads[tgt.cfg.Region]
+		tgt.read = func(ctx context.Context, req *aiplatformpb.ReadFeatureValuesRequest) (*aiplatformpb.ReadFeatureValuesResponse, error) {
+			if err := read(ctx); err != nil {
+				return nil, err
+			}
+			return &aiplatformpb.ReadFeatureValuesResponse{
+				Header:     &aiplatformpb.ReadFeatureValuesResponse_Header{EntityType: req.EntityType},
+				EntityView: &aiplatformpb.ReadFeatureValuesResponse_EntityView{EntityId: req.EntityId},
+			}, nil
+		}
+	}
+	return c
+}
+
+func TestClient_Failover(t *testing.T) {
+	cfg := &Config{
+		ProjectID:        "my-project",
+		Region:           "us-central1",
+		FeatureStoreName: "my_featurestore",
+		Replicas:         []Replica{{Region: "us-east1"}},
+		Failover:         FailoverPolicy{LatencyBudget: 10 * time.Millisecond, Cooldown: time.Minute},
+	}
+	primaryErr := status.Error(codes.Unavailable, "region is down")
+	c := newTestFailoverClient(t, cfg, map[string]func(ctx context.Context) error{
+		"us-central1": func(ctx context.Context) error { return primaryErr },
+		"us-east1":    func(ctx context.Context) error { return nil },
+	})
+	counters := NewCounters()
+	c.metrics = counters
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	query := &Query{EntityType: "my_customer", EntityID: "123", Features: []string{"*"}}
+	e, err := c.GetEntity(context.Background(), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.header.EntityType != "projects/my-project/locations/us-east1/featurestores/my_featurestore/entityTypes/my_customer" {
+		t.Errorf("expected the replica to serve the read, got %v", e.header.EntityType)
+	}
+	if counters.Count(MetricFailovers, "my_customer", "") != 1 {
+		t.Errorf("expected a failover to be counted: %v", counters.Snapshot())
+	}
+	if h := c.Health(); h[0].Healthy || !h[1].Healthy || !h[0].UnhealthyUntil.Equal(now.Add(time.Minute)) {
+		t.Errorf("expected the primary to be unhealthy: %+v", h)
+	}
+
+	// the unhealthy primary is skipped until its cooldown expires, then reads fail back to it
+	primaryErr = nil
+	if _, err := c.GetEntity(context.Background(), query); err != nil {
+		t.Fatal(err)
+	}
+	if c.Health()[0].Healthy {
+		t.Errorf("expected the primary to be skipped during its cooldown")
+	}
+	now = now.Add(2 * time.Minute)
+	e, err = c.GetEntity(context.Background(), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.header.EntityType != "projects/my-project/locations/us-central1/featurestores/my_featurestore/entityTypes/my_customer" ||
+		!c.Health()[0].Healthy {
+		t.Errorf("expected reads to fail back to the primary, got %v", e.header.EntityType)
+	}
+}
+
+func TestClient_FailoverLatencyBudget(t *testing.T) {
+	cfg := &Config{
+		ProjectID:        "my-project",
+		Region:           "us-central1",
+		FeatureStoreName: "my_featurestore",
+		Replicas:         []Replica{{Region: "us-east1"}},
+		Failover:         FailoverPolicy{LatencyBudget: 10 * time.Millisecond},
+	}
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	c := newTestFailoverClient(t, cfg, map[string]func(ctx context.Context) error{
+		"us-central1": slow,
+		"us-east1":    func(ctx context.Context) error { return nil },
+	})
+	if _, err := c.GetEntity(context.Background(), &Query{EntityType: "my_customer", EntityID: "123"}); err != nil {
+		t.Errorf("expected the slow primary to fail over, got %v", err)
+	}
+}
+
+func TestClient_FailoverErrors(t *testing.T) {
+	cfg := &Config{
+		ProjectID:        "my-project",
+		Region:           "us-central1",
+		FeatureStoreName: "my_featurestore",
+		Replicas:         []Replica{{Region: "us-east1"}},
+	}
+	type test struct {
+		name     string
+		primary  error
+		replica  error
+		expected codes.Code
+	}
+	tests := []test{
+		{
+			name:     "not found does not fail over",
+			primary:  status.Error(codes.NotFound, "no such entity type"),
+			expected: codes.NotFound,
+		},
+		{
+			name:     "every region is down",
+			primary:  status.Error(codes.Unavailable, "primary is down"),
+			replica:  status.Error(codes.Unavailable, "replica is down"),
+			expected: codes.Unavailable,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		c := newTestFailoverClient(t, cfg, map[string]func(ctx context.Context) error{
+			"us-central1": func(ctx context.Context) error { return tc.primary },
+			"us-east1":    func(ctx context.Context) error { return tc.replica },
+		})
+		_, err := c.GetEntity(context.Background(), &Query{EntityType: "my_customer", EntityID: "123"})
+		if status.Code(err) != tc.expected {
+			t.Errorf("%v: expected %v, got %v", tc.name, tc.expected, err)
+		}
+	}
+}
+
+func TestConfig_ValidateReplicas(t *testing.T) {
+	type test struct {
+		name string
+		b    ConfigBuilder
+		err  error
+	}
+	base := func() ConfigBuilder {
+		return NewConfigBuilder().WithProjectID("my-project").WithFeatureStoreName("my_featurestore")
+	}
+	tests := []test{
+		{name: "valid", b: base().WithReplicas(Replica{Region: "us-east1"})},
+		{name: "missing region", b: base().WithReplicas(Replica{FeatureStoreName: "other"}), err: ErrInvalidRegion},
+		{name: "unknown region", b: base().WithReplicas(Replica{Region: "us-est1"}), err: ErrInvalidRegion},
+		{
+			name: "negative budget",
+			b:    base().WithFailoverPolicy(FailoverPolicy{LatencyBudget: -time.Second}),
+			err:  ErrInvalidFailoverPolicy,
+		},
+	}
+	for _, tc := range tests {
+		if _, err := tc.b.Apply(); !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+			t.Errorf("%v: expected %v, got %v", tc.name, tc.err, err)
+		}
+	}
+}
+
+// slowStream sends its responses after delay each, or fails once ctx is done.
+type slowStream struct {
+	ctx       context.Context
+	delay     time.Duration
+	responses []*aiplatformpb.ReadFeatureValuesResponse
+}
+
+func (s *slowStream) Recv() (*aiplatformpb.ReadFeatureValuesResponse, error) {
+	select {
+	case <-s.ctx.Done():
+		return nil, status.FromContextError(s.ctx.Err()).Err()
+	case <-time.After(s.delay):
+	}
+	if len(s.responses) == 0 {
+		return nil, io.EOF
+	}
+	res := s.responses[0]
+	s.responses = s.responses[1:]
+	return res, nil
+}
+
+func TestClient_FailoverLatencyBudgetStreaming(t *testing.T) {
+	type test struct {
+		name      string
+		delay     time.Duration
+		failovers int64
+	}
+	tests := []test{
+		{name: "slow stream within budget for its first response", delay: 20 * time.Millisecond},
+		{name: "first response over budget", delay: 200 * time.Millisecond, failovers: 1},
+	}
+	for _, tc := range tests {
+		cfg := &Config{
+			ProjectID:        "my-project",
+			Region:           "us-central1",
+			FeatureStoreName: "my_featurestore",
+			Replicas:         []Replica{{Region: "us-east1"}},
+			Failover:         FailoverPolicy{LatencyBudget: 50 * time.Millisecond},
+		}
+		counters := NewCounters()
+		c := newTestFailoverClient(t, cfg, nil, WithMetrics(counters))
+		for _, tgt := range c.failover.targets {
+			region := tgt.cfg.Region
+			tgt.stream = func(ctx context.Context, req *aiplatformpb.StreamingReadFeatureValuesRequest) (featureValuesStream, error) {
+				header := &aiplatformpb.ReadFeatureValuesResponse_Header{EntityType: region}
+				responses := []*aiplatformpb.ReadFeatureValuesResponse{{Header: header}}
+				for _, id := range req.EntityIds {
+					responses = append(responses, &aiplatformpb.ReadFeatureValuesResponse{
+						EntityView: &aiplatformpb.ReadFeatureValuesResponse_EntityView{EntityId: id},
+					})
+				}
+				delay := tc.delay
+				if region == "us-east1" {
+					delay = 0
+				}
+				return &slowStream{ctx: ctx, delay: delay, responses: responses}, nil
+			}
+		}
+
+		query := &BatchQuery{EntityType: "my_customer", EntityIDs: []string{"1", "2", "3", "4"}, Features: []string{"*"}}
+		entities, err := c.GetEntities(context.Background(), query)
+		if err != nil {
+			t.Fatalf("%v: %v", tc.name, err)
+		}
+		if len(entities) != 4 {
+			t.Errorf("%v: expected 4 entities, got %v", tc.name, len(entities))
+		}
+		if got := counters.Count(MetricFailovers, "my_customer", ""); got != tc.failovers {
+			t.Errorf("%v: expected %v failovers, got %v", tc.name, tc.failovers, got)
+		}
+		if healthy := c.Health()[0].Healthy; healthy != (tc.failovers == 0) {
+			t.Errorf("%v: unexpected primary health %v", tc.name, healthy)
+		}
+	}
+}
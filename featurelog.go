@@ -0,0 +1,197 @@
+package vertigo
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Counters emitted by feature logging.
+const (
+	// MetricFeatureLogsDropped counts the sampled reads that were not logged because the buffer
+	// of the FeatureLogger was full.
+	MetricFeatureLogsDropped = "feature_logs_dropped"
+	// MetricFeatureLogErrors counts the reads the FeatureLogger failed to log.
+	MetricFeatureLogErrors = "feature_log_errors"
+)
+
+// DefaultFeatureLogBuffer is the number of records buffered for the FeatureLogger when
+// FeatureLogOptions does not set a BufferSize.
+const DefaultFeatureLogBuffer = 1024
+
+// FeatureLogRecord describes the features read for a single entity, e.g. the features a model
+// used for a prediction.
+type FeatureLogRecord struct {
+	Time          time.Time
+	CorrelationID string
+	Query         *Query
+	Entity        *Entity
+}
+
+// FeatureLogger receives a FeatureLogRecord after each successful read of an Entity. Records
+// are passed to Log from a single goroutine.
+type FeatureLogger interface {
+	Log(record FeatureLogRecord) error
+}
+
+// FeatureLogOptions controls the logging of WithFeatureLogger.
+type FeatureLogOptions struct {
+	// SampleRate is the fraction of entities that are logged, between 0 and 1.
+	SampleRate float64
+
+	// BufferSize is the number of records waiting to be logged; records beyond it are dropped
+	// rather than blocking reads. It defaults to DefaultFeatureLogBuffer.
+	BufferSize int
+}
+
+type correlationIDKey struct{}
+
+// WithCorrelationID returns a context carrying the correlation ID of a request, e.g. the ID of
+// a prediction, which is added to the records of the reads made with it.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationID returns the correlation ID set on ctx by WithCorrelationID, or "".
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+// featureLog buffers records and passes them to a FeatureLogger from a background goroutine.
+type featureLog struct {
+	logger FeatureLogger
+	opts   FeatureLogOptions
+	sample func() float64
+
+	mu      sync.RWMutex
+	clients int
+	started bool
+	closed  bool
+	records chan FeatureLogRecord
+	done    chan struct{}
+}
+
+// WithFeatureLogger makes the Client log a sample of the entities it reads to logger. Records
+// are buffered and logged asynchronously so logging never blocks reads, and Close flushes the
+// buffer. The Clients created with the same option, such as the Clients of a Router, share the
+// buffer and its goroutine, which is flushed when the last of them is closed.
+func WithFeatureLogger(logger FeatureLogger, opts FeatureLogOptions) ClientOption {
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = DefaultFeatureLogBuffer
+	}
+	l := &featureLog{
+		logger:  logger,
+		opts:    opts,
+		sample:  rand.Float64,
+		records: make(chan FeatureLogRecord, opts.BufferSize),
+		done:    make(chan struct{}),
+	}
+	return func(c *Client) {
+		c.featureLog = l
+	}
+}
+
+// start registers a Client using the log, and starts logging the buffered records with the
+// Metrics of the first one. The Client calls it once every ClientOption is applied.
+func (l *featureLog) start(metrics Metrics) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.clients++
+	if !l.started {
+		l.started = true
+		go l.run(metrics)
+	}
+}
+
+// run logs the buffered records until the buffer is closed.
+func (l *featureLog) run(metrics Metrics) {
+	defer close(l.done)
+	for r := range l.records {
+		if err := l.logger.Log(r); err != nil {
+			metrics.Inc(MetricFeatureLogErrors, r.Query.EntityType, "")
+		}
+	}
+}
+
+// logEntity buffers a record of e, read by query, when it is sampled.
+func (c *Client) logEntity(ctx context.Context, query *Query, e *Entity) {
+	l := c.featureLog
+	if l == nil || l.sample() >= l.opts.SampleRate {
+		return
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		return
+	}
+	select {
+	case l.records <- FeatureLogRecord{Time: c.now(), CorrelationID: CorrelationID(ctx), Query: query.clone(), Entity: e}:
+	default:
+		c.metrics.Inc(MetricFeatureLogsDropped, query.EntityType, "")
+	}
+}
+
+// close unregisters a Client. Once no Client uses the log, it stops accepting records and
+// waits for the buffered ones to be logged.
+func (l *featureLog) close() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	if l.clients > 0 {
+		l.clients--
+	}
+	if l.clients > 0 || !l.started {
+		l.mu.Unlock()
+		return
+	}
+	if !l.closed {
+		l.closed = true
+		close(l.records)
+	}
+	l.mu.Unlock()
+	<-l.done
+}
+
+// featureLogJSON is a line written by the logger of NewJSONLinesLogger.
+type featureLogJSON struct {
+	Time          time.Time `json:"time"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+	EntityType    string    `json:"entity_type"`
+	Features      []string  `json:"features,omitempty"`
+	Store         string    `json:"store,omitempty"`
+	Entity        *Entity   `json:"entity"`
+}
+
+// jsonLinesLogger writes records as newline-delimited JSON.
+type jsonLinesLogger struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONLinesLogger returns a FeatureLogger writing each record to w as a line of JSON holding
+// the time, correlation ID, the entity type, features and store of the query, and the Entity in
+// the form of Entity.MarshalJSON. Use a RotatingFile as w to log to local files.
+func NewJSONLinesLogger(w io.Writer) FeatureLogger {
+	return &jsonLinesLogger{enc: json.NewEncoder(w)}
+}
+
+func (l *jsonLinesLogger) Log(r FeatureLogRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.enc.Encode(featureLogJSON{
+		Time:          r.Time,
+		CorrelationID: r.CorrelationID,
+		EntityType:    r.Query.EntityType,
+		Features:      r.Query.Features,
+		Store:         r.Query.Store,
+		Entity:        r.Entity,
+	})
+}
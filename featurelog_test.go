@@ -0,0 +1,131 @@
+package vertigo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// loggerFunc adapts a function to the FeatureLogger interface.
+type loggerFunc func(r FeatureLogRecord) error
+
+func (f loggerFunc) Log(r FeatureLogRecord) error {
+	return f(r)
+}
+
+func TestClient_FeatureLogger(t *testing.T) {
+	cfg := &Config{ProjectID: "my-project", Region: "us-central1", FeatureStoreName: "my_featurestore"}
+	var buf bytes.Buffer
+	c := newTestFailoverClient(t, cfg, map[string]func(ctx context.Context) error{
+		"us-central1": func(ctx context.Context) error { return nil },
+	}, WithFeatureLogger(NewJSONLinesLogger(&buf), FeatureLogOptions{SampleRate: 1}))
+	c.now = func() time.Time { return time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC) }
+
+	ctx := WithCorrelationID(context.Background(), "prediction-1")
+	for _, id := range []string{"123", "456"} {
+		if _, err := c.GetEntity(ctx, &Query{EntityType: "my_customer", EntityID: id, Features: []string{"*"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", buf.String())
+	}
+	var record struct {
+		Time          time.Time `json:"time"`
+		CorrelationID string    `json:"correlation_id"`
+		EntityType    string    `json:"entity_type"`
+		Features      []string  `json:"features"`
+		Entity        *Entity   `json:"entity"`
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.CorrelationID != "prediction-1" || record.EntityType != "my_customer" || record.Entity.ID != "456" ||
+		!record.Time.Equal(c.now()) || len(record.Features) != 1 {
+		t.Errorf("unexpected record: %v", lines[1])
+	}
+}
+
+func TestClient_FeatureLoggerSampling(t *testing.T) {
+	type test struct {
+		name       string
+		sampleRate float64
+		bufferSize int
+		logErr     error
+		logged     int
+		dropped    int64
+		errors     int64
+	}
+	tests := []test{
+		{name: "not sampled", sampleRate: 0},
+		{name: "sampled", sampleRate: 1, logged: 3},
+		{name: "buffer full", sampleRate: 1, bufferSize: 1, logged: 2, dropped: 1},
+		{name: "logger errors", sampleRate: 1, logErr: errors.New("disk full"), logged: 3, errors: 3},
+	}
+	for _, tc := range tests {
+		counters := NewCounters()
+		var mu sync.Mutex
+		logged := 0
+		started := make(chan struct{}, 3)
+		release := make(chan struct{})
+		c := &Client{metrics: counters, now: time.Now}
+		WithFeatureLogger(loggerFunc(func(r FeatureLogRecord) error {
+			started <- struct{}{}
+			<-release
+			mu.Lock()
+			defer mu.Unlock()
+			logged++
+			return tc.logErr
+		}), FeatureLogOptions{SampleRate: tc.sampleRate, BufferSize: tc.bufferSize})(c)
+		c.featureLog.start(c.metrics)
+
+		query := &Query{EntityType: "my_customer", EntityID: "123"}
+		c.logEntity(context.Background(), query, emptyEntity(query))
+		if tc.sampleRate > 0 {
+			// the first record is being logged, the buffer is empty again
+			<-started
+		}
+		c.logEntity(context.Background(), query, emptyEntity(query))
+		c.logEntity(context.Background(), query, emptyEntity(query))
+		close(release)
+		c.featureLog.close()
+
+		if logged != tc.logged || counters.Count(MetricFeatureLogsDropped, "my_customer", "") != tc.dropped ||
+			counters.Count(MetricFeatureLogErrors, "my_customer", "") != tc.errors {
+			t.Errorf("%v: logged %v, counters %v", tc.name, logged, counters.Snapshot())
+		}
+	}
+}
+
+func TestClient_FeatureLoggerReusedQuery(t *testing.T) {
+	var records []FeatureLogRecord
+	release := make(chan struct{})
+	c := &Client{metrics: NewCounters(), now: time.Now}
+	WithFeatureLogger(loggerFunc(func(r FeatureLogRecord) error {
+		<-release
+		records = append(records, r)
+		return nil
+	}), FeatureLogOptions{SampleRate: 1})(c)
+	c.featureLog.start(c.metrics)
+
+	query := &Query{EntityType: "my_customer", EntityID: "1", Features: []string{"segment"}}
+	c.logEntity(context.Background(), query, emptyEntity(query))
+	query.EntityID = "2"
+	query.Features[0] = "visits"
+	close(release)
+	c.featureLog.close()
+
+	if len(records) != 1 || records[0].Query.EntityID != "1" || records[0].Query.Features[0] != "segment" {
+		t.Errorf("expected the record of the original query, got %+v", records)
+	}
+}
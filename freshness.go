@@ -0,0 +1,206 @@
+package vertigo
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+)
+
+// ErrStaleFeature is returned when a feature value is older than the maximum age allowed by
+// its FreshnessPolicy and the policy's Action is StaleError.
+var ErrStaleFeature = errors.New("feature value is stale")
+
+var ErrInvalidFreshnessPolicy = errors.New("freshness policy is not valid")
+
+// StaleAction decides what happens to a feature value that is older than its maximum age.
+type StaleAction int
+
+const (
+	// StaleError fails the read with a *StaleFeatureError.
+	StaleError StaleAction = iota
+	// StaleDrop removes the value from the Entity, leaving the struct field untouched when scanned.
+	StaleDrop
+	// StaleDefault replaces the value with the zero value of the feature's type.
+	StaleDefault
+)
+
+var staleActionNames = map[StaleAction]string{
+	StaleError:   "error",
+	StaleDrop:    "drop",
+	StaleDefault: "default",
+}
+
+// String returns the configuration name of the action, e.g. "drop".
+func (a StaleAction) String() string {
+	if name, ok := staleActionNames[a]; ok {
+		return name
+	}
+	return fmt.Sprintf("StaleAction(%d)", int(a))
+}
+
+// MarshalText encodes the action as its configuration name.
+func (a StaleAction) MarshalText() ([]byte, error) {
+	name, ok := staleActionNames[a]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown action %d", ErrInvalidFreshnessPolicy, int(a))
+	}
+	return []byte(name), nil
+}
+
+// UnmarshalText decodes an action name: "error", "drop" or "default".
+func (a *StaleAction) UnmarshalText(text []byte) error {
+	for action, name := range staleActionNames {
+		if name == string(text) {
+			*a = action
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: unknown action %q", ErrInvalidFreshnessPolicy, text)
+}
+
+// FreshnessPolicy limits how old the feature values of an entity type may be, based on the
+// generate time reported by the feature store. Values without a generate time are never
+// considered stale.
+type FreshnessPolicy struct {
+	// MaxAge applies to every feature of the entity type. Zero disables the check.
+	MaxAge time.Duration `json:"max_age" yaml:"max_age"`
+
+	// Features overrides MaxAge for individual feature IDs.
+	Features map[string]time.Duration `json:"features,omitempty" yaml:"features,omitempty"`
+
+	// Action is what happens to stale values, "error", "drop" or "default" in configuration
+	// files. Defaults to StaleError.
+	Action StaleAction `json:"action" yaml:"action"`
+}
+
+// UnmarshalJSON decodes the policy, accepting durations such as "15m" as well as integer
+// nanoseconds.
+func (p *FreshnessPolicy) UnmarshalJSON(b []byte) error {
+	in := struct {
+		MaxAge   jsonDuration            `json:"max_age"`
+		Features map[string]jsonDuration `json:"features,omitempty"`
+		Action   StaleAction             `json:"action"`
+	}{MaxAge: jsonDuration(p.MaxAge), Action: p.Action}
+	if err := decodeStrictJSON(b, &in); err != nil {
+		return err
+	}
+	p.MaxAge, p.Action = time.Duration(in.MaxAge), in.Action
+	if in.Features != nil {
+		p.Features = make(map[string]time.Duration, len(in.Features))
+		for id, d := range in.Features {
+			p.Features[id] = time.Duration(d)
+		}
+	}
+	return nil
+}
+
+// StaleFeatureError describes a feature value that is older than its maximum age.
+type StaleFeatureError struct {
+	EntityType string
+	FeatureID  string
+	Age        time.Duration
+	MaxAge     time.Duration
+}
+
+func (e *StaleFeatureError) Error() string {
+	return fmt.Sprintf("%v: %v.%v is %v old, max age is %v", ErrStaleFeature, e.EntityType, e.FeatureID, e.Age, e.MaxAge)
+}
+
+// Unwrap allows errors.Is(err, ErrStaleFeature).
+func (e *StaleFeatureError) Unwrap() error {
+	return ErrStaleFeature
+}
+
+// maxAge returns the maximum age of featureID, or zero when it is unbounded.
+func (p FreshnessPolicy) maxAge(featureID string) time.Duration {
+	if d, ok := p.Features[featureID]; ok {
+		return d
+	}
+	return p.MaxAge
+}
+
+// validate checks the durations and action of the policy.
+func (p FreshnessPolicy) validate() error {
+	if p.MaxAge < 0 {
+		return ErrInvalidFreshnessPolicy
+	}
+	for _, d := range p.Features {
+		if d < 0 {
+			return ErrInvalidFreshnessPolicy
+		}
+	}
+	if p.Action < StaleError || p.Action > StaleDefault {
+		return ErrInvalidFreshnessPolicy
+	}
+	return nil
+}
+
+// enforceFreshness applies policy to every value in e as of now, counting each stale value
+// in metrics. With StaleError the first stale value is returned as a *StaleFeatureError.
+func enforceFreshness(e *Entity, entityType string, policy FreshnessPolicy, now time.Time, metrics Metrics) error {
+	var staleErr error
+	for i, fd := range e.header.GetFeatureDescriptors() {
+		if i >= len(e.data) {
+			break
+		}
+		fv := e.data[i].GetValue()
+		ts := fv.GetMetadata().GetGenerateTime()
+		maxAge := policy.maxAge(fd.Id)
+		if ts == nil || maxAge == 0 {
+			continue
+		}
+		age := now.Sub(ts.AsTime())
+		if age <= maxAge {
+			continue
+		}
+
+		metrics.Inc(MetricStaleFeatures, entityType, fd.Id)
+		switch policy.Action {
+		case StaleDrop:
+			e.data[i] = &aiplatformpb.ReadFeatureValuesResponse_EntityView_Data{}
+		case StaleDefault:
+			e.data[i] = &aiplatformpb.ReadFeatureValuesResponse_EntityView_Data{
+				Data: &aiplatformpb.ReadFeatureValuesResponse_EntityView_Data_Value{Value: zeroFeatureValue(fv)},
+			}
+		default:
+			if staleErr == nil {
+				staleErr = &StaleFeatureError{
+					EntityType: entityType,
+					FeatureID:  fd.Id,
+					Age:        age,
+					MaxAge:     maxAge,
+				}
+			}
+		}
+	}
+	return staleErr
+}
+
+// zeroFeatureValue returns a FeatureValue of the same type as fv holding the zero value,
+// keeping the original metadata.
+func zeroFeatureValue(fv *aiplatformpb.FeatureValue) *aiplatformpb.FeatureValue {
+	zero := &aiplatformpb.FeatureValue{Metadata: fv.GetMetadata()}
+	switch fv.Value.(type) {
+	case *aiplatformpb.FeatureValue_BoolValue:
+		zero.Value = &aiplatformpb.FeatureValue_BoolValue{}
+	case *aiplatformpb.FeatureValue_BoolArrayValue:
+		zero.Value = &aiplatformpb.FeatureValue_BoolArrayValue{BoolArrayValue: &aiplatformpb.BoolArray{}}
+	case *aiplatformpb.FeatureValue_Int64Value:
+		zero.Value = &aiplatformpb.FeatureValue_Int64Value{}
+	case *aiplatformpb.FeatureValue_Int64ArrayValue:
+		zero.Value = &aiplatformpb.FeatureValue_Int64ArrayValue{Int64ArrayValue: &aiplatformpb.Int64Array{}}
+	case *aiplatformpb.FeatureValue_DoubleValue:
+		zero.Value = &aiplatformpb.FeatureValue_DoubleValue{}
+	case *aiplatformpb.FeatureValue_DoubleArrayValue:
+		zero.Value = &aiplatformpb.FeatureValue_DoubleArrayValue{DoubleArrayValue: &aiplatformpb.DoubleArray{}}
+	case *aiplatformpb.FeatureValue_StringValue:
+		zero.Value = &aiplatformpb.FeatureValue_StringValue{}
+	case *aiplatformpb.FeatureValue_StringArrayValue:
+		zero.Value = &aiplatformpb.FeatureValue_StringArrayValue{StringArrayValue: &aiplatformpb.StringArray{}}
+	case *aiplatformpb.FeatureValue_BytesValue:
+		zero.Value = &aiplatformpb.FeatureValue_BytesValue{}
+	}
+	return zero
+}
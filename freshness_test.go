@@ -0,0 +1,135 @@
+package vertigo
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+)
+
+func TestEnforceFreshness(t *testing.T) {
+	now := time.Date(2023, 2, 16, 12, 0, 0, 0, time.UTC)
+	newEntity := func() *Entity {
+		return newTestEntity(map[string]*aiplatformpb.FeatureValue{
+			"fresh":    withGenerateTime(stringFeature("gold"), now.Add(-time.Minute)),
+			"stale":    withGenerateTime(stringFeature("silver"), now.Add(-time.Hour)),
+			"override": withGenerateTime(stringFeature("bronze"), now.Add(-time.Hour)),
+			"no_time":  stringFeature("tin"),
+		})
+	}
+
+	type test struct {
+		name      string
+		action    StaleAction
+		wantErr   bool
+		wantStale string
+		wantOK    bool
+	}
+
+	tests := []test{
+		{name: "error", action: StaleError, wantErr: true},
+		{name: "drop", action: StaleDrop, wantStale: "", wantOK: false},
+		{name: "default", action: StaleDefault, wantStale: "", wantOK: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := newEntity()
+			counters := NewCounters()
+			policy := FreshnessPolicy{
+				MaxAge:   15 * time.Minute,
+				Features: map[string]time.Duration{"override": 2 * time.Hour},
+				Action:   tc.action,
+			}
+			err := enforceFreshness(e, "customer", policy, now, counters)
+
+			if tc.wantErr {
+				var staleErr *StaleFeatureError
+				if !errors.Is(err, ErrStaleFeature) || !errors.As(err, &staleErr) || staleErr.FeatureID != "stale" {
+					t.Errorf("expected a StaleFeatureError for the stale feature, got %v", err)
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				fv, ok := e.featureValue("stale")
+				if ok != tc.wantOK || fv.GetStringValue() != tc.wantStale {
+					t.Errorf("stale value was not replaced: %v, %v", fv, ok)
+				}
+			}
+
+			for _, id := range []string{"fresh", "override", "no_time"} {
+				if _, ok := e.featureValue(id); !ok {
+					t.Errorf("%v should not be stale", id)
+				}
+			}
+			if got := counters.Count(MetricStaleFeatures, "customer", "stale"); got != 1 {
+				t.Errorf("expected 1 stale count, got %v", got)
+			}
+		})
+	}
+}
+
+func TestFreshnessPolicy_Validate(t *testing.T) {
+	_, err := NewConfigBuilder().
+		WithProjectID("my-project").
+		WithFeatureStoreName("my_featurestore").
+		WithFreshnessPolicy("customer", FreshnessPolicy{MaxAge: -time.Minute}).
+		Apply()
+	if !errors.Is(err, ErrInvalidFreshnessPolicy) {
+		t.Errorf("expected ErrInvalidFreshnessPolicy, got %v", err)
+	}
+
+	cfg, err := NewConfigBuilder().
+		WithProjectID("my-project").
+		WithFeatureStoreName("my_featurestore").
+		WithFreshnessPolicy("customer", FreshnessPolicy{MaxAge: time.Minute, Action: StaleDrop}).
+		Apply()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Freshness["customer"].MaxAge != time.Minute {
+		t.Errorf("freshness policy was not set: %v", cfg.Freshness)
+	}
+}
+
+func TestEnforceFreshness_ShortResponse(t *testing.T) {
+	now := time.Date(2023, 2, 16, 12, 0, 0, 0, time.UTC)
+	e := newTestEntity(map[string]*aiplatformpb.FeatureValue{
+		"a": withGenerateTime(stringFeature("gold"), now.Add(-time.Minute)),
+		"b": withGenerateTime(stringFeature("silver"), now.Add(-time.Hour)),
+	})
+	e.data = e.data[:1]
+	policy := FreshnessPolicy{MaxAge: 30 * time.Minute}
+	if err := enforceFreshness(e, "customer", policy, now, nopMetrics{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestStaleAction_Text(t *testing.T) {
+	type test struct {
+		action StaleAction
+		text   string
+	}
+	tests := []test{
+		{action: StaleError, text: "error"},
+		{action: StaleDrop, text: "drop"},
+		{action: StaleDefault, text: "default"},
+	}
+	for _, tc := range tests {
+		b, err := tc.action.MarshalText()
+		if err != nil || string(b) != tc.text {
+			t.Errorf("expected %q, got %q (%v)", tc.text, b, err)
+		}
+		var action StaleAction
+		if err := action.UnmarshalText([]byte(tc.text)); err != nil || action != tc.action {
+			t.Errorf("%v: expected %v, got %v (%v)", tc.text, tc.action, action, err)
+		}
+	}
+
+	var action StaleAction
+	if err := action.UnmarshalText([]byte("ignore")); !errors.Is(err, ErrInvalidFreshnessPolicy) {
+		t.Errorf("expected ErrInvalidFreshnessPolicy, got %v", err)
+	}
+}
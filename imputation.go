@@ -0,0 +1,425 @@
+package vertigo
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MetricImputedFeatures counts feature values that were missing and filled in by an
+// ImputationPolicy.
+const MetricImputedFeatures = "imputed_features"
+
+var ErrInvalidImputationPolicy = errors.New("imputation policy is not valid")
+
+// ErrInvalidImputationStats is returned when a statistic of ImputationStats cannot be converted
+// to the value type of its feature.
+var ErrInvalidImputationStats = errors.New("imputation statistics are not valid")
+
+// DefaultLastKnownCapacity is the number of entities whose last known values are kept for the
+// ImputeLastKnown strategy, unless changed with WithLastKnownCapacity.
+const DefaultLastKnownCapacity = 10000
+
+// ImputationStrategy decides how a missing feature value is filled in.
+type ImputationStrategy string
+
+const (
+	// ImputeConstant uses the constant Value of the rule.
+	ImputeConstant ImputationStrategy = "constant"
+	// ImputeLastKnown uses the last value the Client read for the same entity. The Client keeps
+	// the last values of the most recently read entities in memory, see WithLastKnownCapacity.
+	ImputeLastKnown ImputationStrategy = "last_known"
+	// ImputeMean uses the mean from the ImputationStats given to WithImputationStats.
+	ImputeMean ImputationStrategy = "mean"
+	// ImputeMedian uses the median from the ImputationStats given to WithImputationStats.
+	ImputeMedian ImputationStrategy = "median"
+	// ImputeMode uses the mode from the ImputationStats given to WithImputationStats.
+	ImputeMode ImputationStrategy = "mode"
+)
+
+// ImputationRule is how the missing values of a feature are filled in. Features stay missing
+// when the rule has nothing to impute, e.g. no last known value or no statistic.
+type ImputationRule struct {
+	Strategy ImputationStrategy `json:"strategy" yaml:"strategy"`
+
+	// Type and Value are the constant used by ImputeConstant, e.g. INT64 and 0.
+	Type  ValueType   `json:"type,omitempty" yaml:"type,omitempty"`
+	Value interface{} `json:"value,omitempty" yaml:"value,omitempty"`
+}
+
+// ConstantImputation returns a rule imputing v.
+func ConstantImputation(v Value) ImputationRule {
+	return ImputationRule{Strategy: ImputeConstant, Type: v.Type(), Value: v.Interface()}
+}
+
+// ImputationPolicy holds the imputation rules of an entity type.
+type ImputationPolicy struct {
+	// Default applies to every feature without an entry in Features. Nil disables it.
+	Default *ImputationRule `json:"default,omitempty" yaml:"default,omitempty"`
+
+	// Features holds the rule of individual feature IDs.
+	Features map[string]ImputationRule `json:"features,omitempty" yaml:"features,omitempty"`
+}
+
+// rule returns the rule of featureID.
+func (p ImputationPolicy) rule(featureID string) (ImputationRule, bool) {
+	if r, ok := p.Features[featureID]; ok {
+		return r, true
+	}
+	if p.Default != nil {
+		return *p.Default, true
+	}
+	return ImputationRule{}, false
+}
+
+// validate checks the strategy of every rule, and that constants match their type.
+func (p ImputationPolicy) validate() error {
+	rules := make([]ImputationRule, 0, len(p.Features)+1)
+	for _, r := range p.Features {
+		rules = append(rules, r)
+	}
+	if p.Default != nil {
+		rules = append(rules, *p.Default)
+	}
+	for _, r := range rules {
+		switch r.Strategy {
+		case ImputeConstant:
+			if _, err := valueOfType(r.Type, r.Value); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidImputationPolicy, err)
+			}
+		case ImputeLastKnown, ImputeMean, ImputeMedian, ImputeMode:
+		default:
+			return fmt.Errorf("%w: unknown strategy %q", ErrInvalidImputationPolicy, r.Strategy)
+		}
+	}
+	return nil
+}
+
+// FeatureStatistics are the statistics of a feature used by the statistic strategies. Type is
+// the value type of the feature, which imputed values are converted to, e.g. a mean is rounded
+// for an INT64 feature.
+type FeatureStatistics struct {
+	Type   ValueType   `json:"type" yaml:"type"`
+	Mean   *float64    `json:"mean,omitempty" yaml:"mean,omitempty"`
+	Median *float64    `json:"median,omitempty" yaml:"median,omitempty"`
+	Mode   interface{} `json:"mode,omitempty" yaml:"mode,omitempty"`
+}
+
+// ImputationStats holds FeatureStatistics keyed by entity type ID, then feature ID.
+type ImputationStats map[string]map[string]FeatureStatistics
+
+// LoadImputationStats reads ImputationStats from a YAML (.yaml, .yml) or JSON file, e.g. one
+// computed by the offline training pipeline:
+//
+//	my_customer:
+//	  six_month_spend: {type: DOUBLE, mean: 120.5, median: 80}
+//	  segment: {type: STRING, mode: silver}
+func LoadImputationStats(filename string) (ImputationStats, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	stats := ImputationStats{}
+	if isYAML(filename) {
+		err = yaml.Unmarshal(b, &stats)
+	} else {
+		err = json.Unmarshal(b, &stats)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", filename, err)
+	}
+	if err := stats.validate(); err != nil {
+		return nil, fmt.Errorf("%v: %w", filename, err)
+	}
+	return stats, nil
+}
+
+// validate checks that every statistic can be converted to the value type of its feature.
+func (s ImputationStats) validate() error {
+	for entityType, features := range s {
+		for featureID, stats := range features {
+			for _, strategy := range []ImputationStrategy{ImputeMean, ImputeMedian, ImputeMode} {
+				if _, _, err := stats.value(strategy); err != nil {
+					return fmt.Errorf("%w: %v.%v %v: %v", ErrInvalidImputationStats, entityType, featureID, strategy, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// value returns the statistic of strategy as a Value of the feature type. The boolean is false
+// when the statistic is not set.
+func (s FeatureStatistics) value(strategy ImputationStrategy) (Value, bool, error) {
+	var x interface{}
+	switch strategy {
+	case ImputeMean:
+		if s.Mean != nil {
+			x = *s.Mean
+		}
+	case ImputeMedian:
+		if s.Median != nil {
+			x = *s.Median
+		}
+	case ImputeMode:
+		x = s.Mode
+	}
+	if x == nil {
+		return Value{}, false, nil
+	}
+	if f, ok := x.(float64); ok && s.Type == Int64Type {
+		x = math.Round(f)
+	}
+	v, err := valueOfType(s.Type, x)
+	return v, err == nil, err
+}
+
+// imputer fills in missing feature values according to the ImputationPolicy of each entity type.
+type imputer struct {
+	policies map[string]ImputationPolicy
+	stats    ImputationStats
+
+	mu        sync.Mutex
+	lastKnown *lastKnownCache
+}
+
+func newImputer(policies map[string]ImputationPolicy) *imputer {
+	return &imputer{
+		policies:  policies,
+		lastKnown: newLastKnownCache(DefaultLastKnownCapacity),
+	}
+}
+
+// lastKnownCache holds the last known values of the most recently used entities, evicting the
+// least recently used entity once it holds capacity entities.
+type lastKnownCache struct {
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+type lastKnownEntry struct {
+	key    string
+	values map[string]Value
+}
+
+func newLastKnownCache(capacity int) *lastKnownCache {
+	return &lastKnownCache{capacity: capacity, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+// get returns the last known value of featureID for the entity key.
+func (c *lastKnownCache) get(key, featureID string) (Value, bool) {
+	el, ok := c.entries[key]
+	if !ok {
+		return Value{}, false
+	}
+	c.order.MoveToFront(el)
+	v, ok := el.Value.(*lastKnownEntry).values[featureID]
+	return v, ok
+}
+
+// set records v as the last known value of featureID for the entity key.
+func (c *lastKnownCache) set(key, featureID string, v Value) {
+	if el, ok := c.entries[key]; ok {
+		c.order.MoveToFront(el)
+		el.Value.(*lastKnownEntry).values[featureID] = v
+		return
+	}
+	c.entries[key] = c.order.PushFront(&lastKnownEntry{key: key, values: map[string]Value{featureID: v}})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lastKnownEntry).key)
+	}
+}
+
+// len returns the number of entities in the cache.
+func (c *lastKnownCache) len() int {
+	return c.order.Len()
+}
+
+// apply imputes the missing values of e, counting each imputed value in metrics, and records
+// the values of features imputed with ImputeLastKnown for later reads.
+func (im *imputer) apply(e *Entity, entityType string, metrics Metrics) error {
+	policy, ok := im.policies[entityType]
+	if !ok {
+		return nil
+	}
+	key := entityType + "/" + e.ID
+
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	for _, id := range e.FeatureIDs() {
+		rule, ok := policy.rule(id)
+		if !ok {
+			continue
+		}
+		if v, ok := e.Value(id); ok {
+			if rule.Strategy == ImputeLastKnown {
+				im.lastKnown.set(key, id, v)
+			}
+			continue
+		}
+
+		v, ok, err := im.impute(rule, key, entityType, id)
+		if err != nil {
+			return fmt.Errorf("imputing %v.%v: %w", entityType, id, err)
+		}
+		if !ok {
+			continue
+		}
+		e.setValue(id, v)
+		metrics.Inc(MetricImputedFeatures, entityType, id)
+	}
+	return nil
+}
+
+// impute returns the value rule imputes for a feature. The boolean is false when there is
+// nothing to impute.
+func (im *imputer) impute(rule ImputationRule, key, entityType, featureID string) (Value, bool, error) {
+	switch rule.Strategy {
+	case ImputeConstant:
+		v, err := valueOfType(rule.Type, rule.Value)
+		return v, err == nil, err
+	case ImputeLastKnown:
+		v, ok := im.lastKnown.get(key, featureID)
+		return v, ok, nil
+	}
+
+	stats, ok := im.stats[entityType][featureID]
+	if !ok {
+		return Value{}, false, nil
+	}
+	return stats.value(rule.Strategy)
+}
+
+// valueOfType converts x, as decoded from JSON or YAML or given in Go, into a Value of type t.
+func valueOfType(t ValueType, x interface{}) (Value, error) {
+	switch t {
+	case BoolType:
+		if b, ok := x.(bool); ok {
+			return NewBoolValue(b), nil
+		}
+	case Int64Type:
+		switch i := x.(type) {
+		case int64:
+			return NewInt64Value(i), nil
+		case int:
+			return NewInt64Value(int64(i)), nil
+		case float64:
+			if i == math.Trunc(i) {
+				return NewInt64Value(int64(i)), nil
+			}
+		}
+	case DoubleType:
+		if f, ok := toFloat64(x); ok {
+			return NewFloat64Value(f), nil
+		}
+	case StringType:
+		if s, ok := x.(string); ok {
+			return NewStringValue(s), nil
+		}
+	case BytesType:
+		switch b := x.(type) {
+		case []byte:
+			return NewBytesValue(b), nil
+		case string:
+			return NewBytesValue([]byte(b)), nil
+		}
+	case BoolArrayType, Int64ArrayType, DoubleArrayType, StringArrayType:
+		return arrayOfType(t, x)
+	}
+	return Value{}, fmt.Errorf("%v (%T) is not a valid %v value", x, x, t)
+}
+
+// arrayOfType converts x into an array Value of type t. x may be a typed slice or a
+// []interface{} as decoded from JSON or YAML.
+func arrayOfType(t ValueType, x interface{}) (Value, error) {
+	switch s := x.(type) {
+	case []bool:
+		if t == BoolArrayType {
+			return NewBoolSliceValue(s), nil
+		}
+	case []int64:
+		if t == Int64ArrayType {
+			return NewInt64SliceValue(s), nil
+		}
+	case []float64:
+		if t == DoubleArrayType {
+			return NewFloat64SliceValue(s), nil
+		}
+	case []string:
+		if t == StringArrayType {
+			return NewStringSliceValue(s), nil
+		}
+	case []interface{}:
+		elemType := map[ValueType]ValueType{
+			BoolArrayType:   BoolType,
+			Int64ArrayType:  Int64Type,
+			DoubleArrayType: DoubleType,
+			StringArrayType: StringType,
+		}[t]
+		elems := make([]Value, 0, len(s))
+		for _, e := range s {
+			v, err := valueOfType(elemType, e)
+			if err != nil {
+				return Value{}, err
+			}
+			elems = append(elems, v)
+		}
+		return collectArray(t, elems), nil
+	}
+	return Value{}, fmt.Errorf("%v (%T) is not a valid %v value", x, x, t)
+}
+
+// collectArray builds an array Value of type t from scalar Values of the element type.
+func collectArray(t ValueType, elems []Value) Value {
+	switch t {
+	case BoolArrayType:
+		s := make([]bool, 0, len(elems))
+		for _, e := range elems {
+			b, _ := e.AsBool()
+			s = append(s, b)
+		}
+		return NewBoolSliceValue(s)
+	case Int64ArrayType:
+		s := make([]int64, 0, len(elems))
+		for _, e := range elems {
+			i, _ := e.AsInt64()
+			s = append(s, i)
+		}
+		return NewInt64SliceValue(s)
+	case DoubleArrayType:
+		s := make([]float64, 0, len(elems))
+		for _, e := range elems {
+			f, _ := e.AsFloat64()
+			s = append(s, f)
+		}
+		return NewFloat64SliceValue(s)
+	}
+	s := make([]string, 0, len(elems))
+	for _, e := range elems {
+		str, _ := e.AsString()
+		s = append(s, str)
+	}
+	return NewStringSliceValue(s)
+}
+
+// toFloat64 converts the numeric types produced by encoding/json, yaml.v3 and Go literals.
+func toFloat64(x interface{}) (float64, bool) {
+	switch n := x.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
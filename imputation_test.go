@@ -0,0 +1,193 @@
+package vertigo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	aiplatform "cloud.google.com/go/aiplatform/apiv1beta1"
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+)
+
+func TestImputer_Apply(t *testing.T) {
+	stats := filepath.Join(t.TempDir(), "stats.yaml")
+	err := os.WriteFile(stats, []byte(`
+my_customer:
+  six_month_spend: {type: DOUBLE, mean: 120.5, median: 80}
+  visits: {type: INT64, mean: 3.6}
+  segment: {type: STRING, mode: silver}
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadImputationStats(stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	im := newImputer(map[string]ImputationPolicy{
+		"my_customer": {
+			Default: &ImputationRule{Strategy: ImputeLastKnown},
+			Features: map[string]ImputationRule{
+				"six_month_spend": {Strategy: ImputeMedian},
+				"visits":          {Strategy: ImputeMean},
+				"segment":         {Strategy: ImputeMode},
+				"audiences":       ConstantImputation(NewStringSliceValue([]string{"none"})),
+			},
+		},
+	})
+	im.stats = loaded
+	counters := NewCounters()
+
+	first := newTestEntity(map[string]*aiplatformpb.FeatureValue{
+		"last_purchase": stringFeature("shoes"),
+	})
+	if err := im.apply(first, "my_customer", counters); err != nil {
+		t.Fatal(err)
+	}
+
+	missing := newTestEntity(map[string]*aiplatformpb.FeatureValue{
+		"six_month_spend": nil,
+		"visits":          nil,
+		"segment":         nil,
+		"audiences":       nil,
+		"last_purchase":   nil,
+	})
+	if err := im.apply(missing, "my_customer", counters); err != nil {
+		t.Fatal(err)
+	}
+
+	type customer struct {
+		SixMonthSpend float64  `vertex:"six_month_spend"`
+		Visits        int64    `vertex:"visits"`
+		Segment       string   `vertex:"segment"`
+		Audiences     []string `vertex:"audiences"`
+		LastPurchase  string   `vertex:"last_purchase"`
+	}
+	c := customer{}
+	if err := missing.ScanStruct(&c); err != nil {
+		t.Fatal(err)
+	}
+	if c.SixMonthSpend != 80 || c.Visits != 4 || c.Segment != "silver" || len(c.Audiences) != 1 || c.LastPurchase != "shoes" {
+		t.Errorf("unexpected imputed values: %+v", c)
+	}
+	if got := counters.Count(MetricImputedFeatures, "my_customer", "visits"); got != 1 {
+		t.Errorf("expected 1 imputation of visits, got %v", got)
+	}
+	if got := len(counters.Snapshot()); got != 5 {
+		t.Errorf("expected 5 imputed features, got %v", got)
+	}
+}
+
+func TestImputationPolicy_Validate(t *testing.T) {
+	type test struct {
+		name   string
+		policy ImputationPolicy
+		err    error
+	}
+
+	tests := []test{
+		{
+			name:   "valid constant",
+			policy: ImputationPolicy{Default: &ImputationRule{Strategy: ImputeConstant, Type: Int64Type, Value: 0}},
+		},
+		{
+			name:   "constant of the wrong type",
+			policy: ImputationPolicy{Default: &ImputationRule{Strategy: ImputeConstant, Type: Int64Type, Value: "zero"}},
+			err:    ErrInvalidImputationPolicy,
+		},
+		{
+			name:   "unknown strategy",
+			policy: ImputationPolicy{Features: map[string]ImputationRule{"a": {Strategy: "max"}}},
+			err:    ErrInvalidImputationPolicy,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewConfigBuilder().
+				WithProjectID("my-project").
+				WithFeatureStoreName("my_featurestore").
+				WithImputationPolicy("my_customer", tc.policy).
+				Apply()
+			if !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+				t.Errorf("%v: expected %v, got %v", tc.name, tc.err, err)
+			}
+		})
+	}
+}
+
+func TestLastKnownCache(t *testing.T) {
+	c := newLastKnownCache(2)
+	c.set("my_customer/1", "segment", NewStringValue("gold"))
+	c.set("my_customer/2", "segment", NewStringValue("silver"))
+	if _, ok := c.get("my_customer/1", "segment"); !ok {
+		t.Fatal("expected a value for entity 1")
+	}
+	c.set("my_customer/3", "segment", NewStringValue("bronze"))
+
+	if c.len() != 2 {
+		t.Errorf("expected 2 entities, got %v", c.len())
+	}
+	if _, ok := c.get("my_customer/2", "segment"); ok {
+		t.Error("expected the least recently used entity to be evicted")
+	}
+	if v, ok := c.get("my_customer/1", "segment"); !ok || v.String() != "gold" {
+		t.Errorf("expected gold for entity 1, got %v", v)
+	}
+}
+
+func TestLoadImputationStats_Invalid(t *testing.T) {
+	type test struct {
+		name    string
+		content string
+	}
+	tests := []test{
+		{name: "mean of a string", content: "my_customer:\n  segment: {type: STRING, mean: 1.5}\n"},
+		{name: "mode of the wrong type", content: "my_customer:\n  visits: {type: INT64, mode: many}\n"},
+	}
+	for _, tc := range tests {
+		filename := filepath.Join(t.TempDir(), "stats.yaml")
+		if err := os.WriteFile(filename, []byte(tc.content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadImputationStats(filename); !errors.Is(err, ErrInvalidImputationStats) {
+			t.Errorf("%v: expected ErrInvalidImputationStats, got %v", tc.name, err)
+		}
+	}
+
+	mean := 1.5
+	stats := ImputationStats{"my_customer": {"segment": {Type: StringType, Mean: &mean}}}
+	dial := func(ctx context.Context, endpoint string) (*aiplatform.FeaturestoreOnlineServingClient, error) {
+		return nil, nil
+	}
+	_, err := newClient(context.Background(), &Config{}, dial, WithImputationStats(stats))
+	if !errors.Is(err, ErrInvalidImputationStats) {
+		t.Errorf("expected ErrInvalidImputationStats from newClient, got %v", err)
+	}
+}
+
+func TestImputer_ApplyShortResponse(t *testing.T) {
+	rule := ConstantImputation(NewStringValue("none"))
+	im := newImputer(map[string]ImputationPolicy{"my_customer": {Default: &rule}})
+	e := newTestEntity(map[string]*aiplatformpb.FeatureValue{
+		"last_purchase": stringFeature("shoes"),
+		"segment":       nil,
+	})
+	e.data = e.data[:1]
+	if err := im.apply(e, "my_customer", NewCounters()); err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := e.String("segment"); !ok || s != "none" {
+		t.Errorf("expected segment to be imputed, got %v", s)
+	}
+
+	view := &aiplatformpb.ReadFeatureValuesResponse_EntityView{EntityId: e.ID, Data: e.data[:1]}
+	c := &Client{cfg: &Config{}, imputer: im, metrics: nopMetrics{}, now: time.Now}
+	if _, err := c.newEntity("my_customer", "", e.header, view); !errors.Is(err, ErrDescriptorMismatch) {
+		t.Errorf("expected ErrDescriptorMismatch, got %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package vertigo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// JoinQuery reads several entity types at once, e.g. the customer, product and merchant of a
+// single prediction.
+type JoinQuery struct {
+	// Queries maps an alias to the Query of an entity type. The alias is the prefix used in
+	// the vertex tags of the composite struct, e.g. `vertex:"customer.segment"`.
+	Queries map[string]*Query
+}
+
+// JoinedEntity holds the entities read by GetJoined, keyed by alias.
+type JoinedEntity struct {
+	Entities map[string]*Entity
+}
+
+// JoinError is returned by GetJoined when some of the queries fail. It holds the error of each
+// failed query, keyed by alias.
+type JoinError struct {
+	Errors map[string]error
+}
+
+func (e *JoinError) Error() string {
+	aliases := e.aliases()
+	msgs := make([]string, 0, len(aliases))
+	for _, alias := range aliases {
+		msgs = append(msgs, fmt.Sprintf("%v: %v", alias, e.Errors[alias]))
+	}
+	return "join failed: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the error of every failed query, ordered by alias.
+func (e *JoinError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, alias := range e.aliases() {
+		errs = append(errs, e.Errors[alias])
+	}
+	return errs
+}
+
+// Is reports whether the error of any failed query matches target, so errors.Is works on
+// versions of Go that do not unwrap multiple errors.
+func (e *JoinError) Is(target error) bool {
+	for _, err := range e.Unwrap() {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first error of the failed queries, ordered by alias, that matches target.
+func (e *JoinError) As(target interface{}) bool {
+	for _, err := range e.Unwrap() {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// aliases returns the sorted aliases of the failed queries.
+func (e *JoinError) aliases() []string {
+	aliases := make([]string, 0, len(e.Errors))
+	for alias := range e.Errors {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	return aliases
+}
+
+// GetJoined concurrently reads every query of q with GetEntity. When some queries fail, the
+// entities that were read are still returned alongside a *JoinError.
+func (c *Client) GetJoined(ctx context.Context, q JoinQuery) (*JoinedEntity, error) {
+	return getJoined(ctx, q, c.GetEntity)
+}
+
+// getJoined concurrently reads every query of q with get.
+func getJoined(
+	ctx context.Context,
+	q JoinQuery,
+	get func(ctx context.Context, query *Query) (*Entity, error),
+) (*JoinedEntity, error) {
+	joined := &JoinedEntity{Entities: map[string]*Entity{}}
+	errs := map[string]error{}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for alias, query := range q.Queries {
+		wg.Add(1)
+		go func(alias string, query *Query) {
+			defer wg.Done()
+			e, err := get(ctx, query)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs[alias] = err
+				return
+			}
+			joined.Entities[alias] = e
+		}(alias, query)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return joined, &JoinError{Errors: errs}
+	}
+	return joined, nil
+}
+
+// ScanStruct loads the features of every entity into dst, which must be a pointer to a struct
+// whose vertex tags are prefixed with the alias of the entity, e.g. `vertex:"customer.segment"`.
+// Tag options and ScanOptions behave as in Entity.ScanStruct, and a `vertex:",remaining"` field
+// collects unmapped features keyed by "<alias>.<feature ID>".
+func (j *JoinedEntity) ScanStruct(dst interface{}, opts ...ScanOption) error {
+	if err := isStructPointer(dst); err != nil {
+		return err
+	}
+	so := &scanOptions{}
+	for _, opt := range opts {
+		opt(so)
+	}
+
+	aliases := make([]string, 0, len(j.Entities))
+	for alias := range j.Entities {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	var unmapped []string
+	for _, alias := range aliases {
+		u, err := j.Entities[alias].scanStruct(dst, alias+".")
+		if err != nil {
+			return fmt.Errorf("%v: %w", alias, err)
+		}
+		unmapped = append(unmapped, u...)
+	}
+	if so.strict && len(unmapped) > 0 {
+		return fmt.Errorf("%w: %v", ErrUnmappedFeature, strings.Join(unmapped, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package vertigo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+)
+
+func TestJoinedEntity_ScanStruct(t *testing.T) {
+	type prediction struct {
+		Segment   string                 `vertex:"customer.segment"`
+		Category  string                 `vertex:"product.category"`
+		Remaining map[string]interface{} `vertex:",remaining"`
+	}
+
+	joined := &JoinedEntity{Entities: map[string]*Entity{
+		"customer": newTestEntity(map[string]*aiplatformpb.FeatureValue{
+			"segment": stringFeature("gold"),
+		}),
+		"product": newTestEntity(map[string]*aiplatformpb.FeatureValue{
+			"category": stringFeature("shoes"),
+			"price":    {Value: &aiplatformpb.FeatureValue_DoubleValue{DoubleValue: 10}},
+		}),
+	}}
+
+	p := prediction{}
+	if err := joined.ScanStruct(&p, Strict()); err != nil {
+		t.Fatal(err)
+	}
+	if p.Segment != "gold" || p.Category != "shoes" || p.Remaining["product.price"] != 10.0 {
+		t.Errorf("joined entities were not scanned: %+v", p)
+	}
+
+	type strict struct {
+		Segment string `vertex:"customer.segment"`
+	}
+	err := joined.ScanStruct(&strict{}, Strict())
+	if !errors.Is(err, ErrUnmappedFeature) || !strings.Contains(err.Error(), "product.category, product.price") {
+		t.Errorf("expected unmapped product features, got %v", err)
+	}
+}
+
+func TestJoinError(t *testing.T) {
+	err := error(&JoinError{Errors: map[string]error{
+		"product":  ErrStaleFeature,
+		"customer": ErrUnknownFeature,
+	}})
+	if !strings.HasPrefix(err.Error(), "join failed: customer: ") {
+		t.Errorf("errors should be sorted by alias: %v", err)
+	}
+	var joinErr *JoinError
+	if !errors.As(err, &joinErr) || len(joinErr.Errors) != 2 {
+		t.Errorf("expected a JoinError, got %v", err)
+	}
+	if len(joinErr.Unwrap()) != 2 {
+		t.Errorf("expected 2 wrapped errors, got %v", joinErr.Unwrap())
+	}
+	if !errors.Is(err, ErrStaleFeature) || !errors.Is(err, ErrUnknownFeature) || errors.Is(err, ErrNoFeatures) {
+		t.Errorf("errors.Is should match the error of any query: %v", err)
+	}
+
+	err = &JoinError{Errors: map[string]error{
+		"product": &StaleFeatureError{EntityType: "my_product", FeatureID: "price"},
+	}}
+	var staleErr *StaleFeatureError
+	if !errors.As(err, &staleErr) || staleErr.FeatureID != "price" {
+		t.Errorf("expected a StaleFeatureError, got %v", err)
+	}
+}
+
+func TestGetJoined_PartialFailure(t *testing.T) {
+	get := func(ctx context.Context, query *Query) (*Entity, error) {
+		if query.EntityType == "my_product" {
+			return nil, ErrStaleFeature
+		}
+		return newTestEntity(map[string]*aiplatformpb.FeatureValue{"segment": stringFeature("gold")}), nil
+	}
+
+	joined, err := getJoined(context.Background(), JoinQuery{Queries: map[string]*Query{
+		"customer": {EntityType: "my_customer", EntityID: "1", Features: []string{"segment"}},
+		"product":  {EntityType: "my_product", EntityID: "2", Features: []string{"price"}},
+	}}, get)
+
+	var joinErr *JoinError
+	if !errors.As(err, &joinErr) || len(joinErr.Errors) != 1 || !errors.Is(joinErr.Errors["product"], ErrStaleFeature) {
+		t.Fatalf("expected a JoinError for the product, got %v", err)
+	}
+	if len(joined.Entities) != 1 || !joined.Entities["customer"].Has("segment") {
+		t.Errorf("expected the customer entity to be returned, got %v", joined.Entities)
+	}
+}
@@ -0,0 +1,292 @@
+package vertigo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+	"google.golang.org/protobuf/proto"
+)
+
+// entityJSON is the JSON encoding of an Entity, documented on Entity.MarshalJSON.
+type entityJSON struct {
+	EntityID   string                         `json:"entity_id"`
+	EntityType string                         `json:"entity_type,omitempty"`
+	Features   map[string]interface{}         `json:"features"`
+	Metadata   map[string]featureMetadataJSON `json:"metadata,omitempty"`
+}
+
+// featureMetadataJSON is the JSON encoding of the metadata of a single feature value.
+type featureMetadataJSON struct {
+	ValueType    string     `json:"value_type"`
+	GenerateTime *time.Time `json:"generate_time,omitempty"`
+	Redacted     bool       `json:"redacted,omitempty"`
+}
+
+// jsonFloat encodes a DOUBLE value, writing the non-finite values encoding/json rejects as the
+// strings "NaN", "Inf" and "-Inf".
+type jsonFloat float64
+
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	switch x := float64(f); {
+	case math.IsNaN(x):
+		return []byte(`"NaN"`), nil
+	case math.IsInf(x, 1):
+		return []byte(`"Inf"`), nil
+	case math.IsInf(x, -1):
+		return []byte(`"-Inf"`), nil
+	}
+	return json.Marshal(float64(f))
+}
+
+func (f *jsonFloat) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return json.Unmarshal(b, (*float64)(f))
+	}
+	switch s {
+	case "NaN":
+		*f = jsonFloat(math.NaN())
+	case "Inf":
+		*f = jsonFloat(math.Inf(1))
+	case "-Inf":
+		*f = jsonFloat(math.Inf(-1))
+	default:
+		return fmt.Errorf("%q is not a DOUBLE value", s)
+	}
+	return nil
+}
+
+// toJSONFloats converts the DOUBLE and DOUBLE_ARRAY values of features to jsonFloat.
+func toJSONFloats(features map[string]interface{}) {
+	for id, x := range features {
+		switch v := x.(type) {
+		case float64:
+			features[id] = jsonFloat(v)
+		case []float64:
+			fs := make([]jsonFloat, len(v))
+			for i, f := range v {
+				fs[i] = jsonFloat(f)
+			}
+			features[id] = fs
+		}
+	}
+}
+
+// ToMap returns every feature of the Entity keyed by feature ID, with values of their natural
+// Go type (see Value.Interface). Features without a value map to nil.
+func (e *Entity) ToMap() map[string]interface{} {
+	m := make(map[string]interface{}, len(e.header.GetFeatureDescriptors()))
+	for _, id := range e.FeatureIDs() {
+		v, _ := e.Value(id)
+		m[id] = v.Interface()
+	}
+	return m
+}
+
+// scanMap merges the features of the Entity into the map pointed to by dst, allocating it
+// when nil.
+func (e *Entity) scanMap(dst *map[string]interface{}) error {
+	if dst == nil {
+		return errors.New("dst must not be a nil pointer")
+	}
+	if *dst == nil {
+		*dst = map[string]interface{}{}
+	}
+	for k, v := range e.ToMap() {
+		(*dst)[k] = v
+	}
+	return nil
+}
+
+// MarshalJSON encodes the Entity as a JSON object holding the entity ID, entity type, the
+// feature values and their metadata. The shape is stable:
+//
+//	{
+//	  "entity_id": "123abc",
+//	  "entity_type": "projects/p/locations/l/featurestores/fs/entityTypes/my_customer",
+//	  "features": {"segment": "gold", "no_value": null},
+//	  "metadata": {"segment": {"value_type": "STRING", "generate_time": "2023-02-16T12:00:00Z"}}
+//	}
+//
+// generate_time is omitted when unknown, and metadata is omitted when no feature has a value.
+// The values of sensitive features are encoded as null, with "redacted": true in their metadata.
+// NaN and infinite DOUBLE values are encoded as the strings "NaN", "Inf" and "-Inf".
+func (e *Entity) MarshalJSON() ([]byte, error) {
+	out := entityJSON{
+		EntityID:   e.ID,
+		EntityType: e.header.GetEntityType(),
+		Features:   e.ToMap(),
+	}
+	toJSONFloats(out.Features)
+	for _, id := range e.FeatureIDs() {
+		v, ok := e.Value(id)
+		if !ok {
+			continue
+		}
+		if out.Metadata == nil {
+			out.Metadata = map[string]featureMetadataJSON{}
+		}
+		md := featureMetadataJSON{ValueType: v.Type().String()}
+		if gt := v.GenerateTime(); !gt.IsZero() {
+			md.GenerateTime = &gt
+		}
+		if e.Sensitive(id) {
+			out.Features[id] = nil
+			md.Redacted = true
+		}
+		out.Metadata[id] = md
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes an Entity encoded by MarshalJSON, replacing the contents of e. The
+// feature descriptors are ordered by feature ID, as JSON objects do not preserve the order of
+// the original response. Redacted features stay sensitive and have no value.
+func (e *Entity) UnmarshalJSON(b []byte) error {
+	var in struct {
+		EntityID   string                         `json:"entity_id"`
+		EntityType string                         `json:"entity_type"`
+		Features   map[string]json.RawMessage     `json:"features"`
+		Metadata   map[string]featureMetadataJSON `json:"metadata"`
+	}
+	if err := json.Unmarshal(b, &in); err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(in.Features))
+	for id := range in.Features {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	header := &aiplatformpb.ReadFeatureValuesResponse_Header{EntityType: in.EntityType}
+	data := make([]*aiplatformpb.ReadFeatureValuesResponse_EntityView_Data, 0, len(ids))
+	var sensitive map[string]bool
+	for _, id := range ids {
+		if in.Metadata[id].Redacted {
+			if sensitive == nil {
+				sensitive = map[string]bool{}
+			}
+			sensitive[id] = true
+		}
+		fv, err := featureValueFromJSON(in.Features[id], in.Metadata[id])
+		if err != nil {
+			return fmt.Errorf("feature %v: %w", id, err)
+		}
+		header.FeatureDescriptors = append(
+			header.FeatureDescriptors,
+			&aiplatformpb.ReadFeatureValuesResponse_FeatureDescriptor{Id: id},
+		)
+		data = append(data, &aiplatformpb.ReadFeatureValuesResponse_EntityView_Data{
+			Data: &aiplatformpb.ReadFeatureValuesResponse_EntityView_Data_Value{Value: fv},
+		})
+	}
+	e.ID, e.header, e.data, e.sensitive = in.EntityID, header, data, sensitive
+	return nil
+}
+
+// featureValueFromJSON decodes the JSON value of a feature according to the value type in its
+// metadata. A null value yields nil.
+func featureValueFromJSON(raw json.RawMessage, md featureMetadataJSON) (*aiplatformpb.FeatureValue, error) {
+	if string(raw) == "null" {
+		return nil, nil
+	}
+	var vt ValueType
+	if err := vt.UnmarshalText([]byte(md.ValueType)); err != nil {
+		return nil, err
+	}
+
+	var dst interface{}
+	switch vt {
+	case BoolType:
+		dst = new(bool)
+	case Int64Type:
+		dst = new(int64)
+	case DoubleType:
+		dst = new(jsonFloat)
+	case StringType:
+		dst = new(string)
+	case BytesType:
+		dst = new([]byte)
+	case BoolArrayType:
+		dst = new([]bool)
+	case Int64ArrayType:
+		dst = new([]int64)
+	case DoubleArrayType:
+		dst = new([]jsonFloat)
+	case StringArrayType:
+		dst = new([]string)
+	default:
+		return nil, fmt.Errorf("value of unknown type %v", vt)
+	}
+	if err := json.Unmarshal(raw, dst); err != nil {
+		return nil, err
+	}
+
+	x := reflect.ValueOf(dst).Elem().Interface()
+	switch f := x.(type) {
+	case jsonFloat:
+		x = float64(f)
+	case []jsonFloat:
+		fs := make([]float64, len(f))
+		for i := range f {
+			fs[i] = float64(f[i])
+		}
+		x = fs
+	}
+	v, err := valueOfType(vt, x)
+	if err != nil {
+		return nil, err
+	}
+	if md.GenerateTime != nil {
+		v.generateTime = *md.GenerateTime
+	}
+	return v.toProto(), nil
+}
+
+// MarshalBinary encodes the Entity as a ReadFeatureValuesResponse in the protobuf wire format,
+// preserving the header, feature descriptors, values and their metadata, e.g. to cache the
+// Entity in an external store or record what a model saw at prediction time. Sensitive features
+// are encoded with an empty value list in place of their value, so UnmarshalBinary restores them
+// as sensitive features without a value, like UnmarshalJSON, rather than as missing values.
+func (e *Entity) MarshalBinary() ([]byte, error) {
+	return proto.Marshal(&aiplatformpb.ReadFeatureValuesResponse{
+		Header: e.header,
+		EntityView: &aiplatformpb.ReadFeatureValuesResponse_EntityView{
+			EntityId: e.ID,
+			Data:     e.redactedData(),
+		},
+	})
+}
+
+// UnmarshalBinary decodes an Entity encoded by MarshalBinary, replacing the contents of e.
+// Redacted features stay sensitive and have no value.
+func (e *Entity) UnmarshalBinary(b []byte) error {
+	res := &aiplatformpb.ReadFeatureValuesResponse{}
+	if err := proto.Unmarshal(b, res); err != nil {
+		return err
+	}
+	e.ID = res.GetEntityView().GetEntityId()
+	e.header = res.GetHeader()
+	e.data = res.GetEntityView().GetData()
+	e.sensitive = nil
+	for i, d := range e.data {
+		if isRedacted(d) && i < len(e.header.GetFeatureDescriptors()) {
+			e.markSensitive(e.header.FeatureDescriptors[i].Id)
+			d.Data = &aiplatformpb.ReadFeatureValuesResponse_EntityView_Data_Value{}
+			continue
+		}
+		// a nil FeatureValue is encoded as an empty message, restore it so the feature stays
+		// without a value
+		if fv := d.GetValue(); fv != nil && proto.Size(fv) == 0 {
+			d.Data = &aiplatformpb.ReadFeatureValuesResponse_EntityView_Data_Value{}
+		}
+	}
+	return nil
+}
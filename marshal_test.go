@@ -0,0 +1,151 @@
+package vertigo
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+)
+
+func TestEntity_ToMap(t *testing.T) {
+	entity := newTestEntity(map[string]*aiplatformpb.FeatureValue{
+		"segment": stringFeature("gold"),
+		"visits":  {Value: &aiplatformpb.FeatureValue_Int64Value{Int64Value: 3}},
+		"empty":   nil,
+	})
+
+	m := entity.ToMap()
+	if m["segment"] != "gold" || m["visits"] != int64(3) || m["empty"] != nil || len(m) != 3 {
+		t.Errorf("unexpected ToMap(): %v", m)
+	}
+
+	scanned := map[string]interface{}{"existing": true}
+	if err := entity.ScanStruct(&scanned); err != nil {
+		t.Fatal(err)
+	}
+	if scanned["segment"] != "gold" || scanned["existing"] != true {
+		t.Errorf("ScanStruct did not merge into the map: %v", scanned)
+	}
+
+	var nilMap map[string]interface{}
+	if err := entity.ScanStruct(&nilMap); err != nil || len(nilMap) != 3 {
+		t.Errorf("ScanStruct did not allocate the map: %v, %v", nilMap, err)
+	}
+}
+
+func TestEntity_MarshalJSON(t *testing.T) {
+	generated := time.Date(2023, 2, 16, 12, 0, 0, 0, time.UTC)
+	entity := newTestEntity(map[string]*aiplatformpb.FeatureValue{
+		"segment": withGenerateTime(stringFeature("gold"), generated),
+		"spend":   {Value: &aiplatformpb.FeatureValue_DoubleValue{DoubleValue: 12.5}},
+		"empty":   nil,
+	})
+
+	b, err := json.Marshal(entity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"entity_id":"123","entity_type":"my_entity",` +
+		`"features":{"empty":null,"segment":"gold","spend":12.5},` +
+		`"metadata":{"segment":{"value_type":"STRING","generate_time":"2023-02-16T12:00:00Z"},"spend":{"value_type":"DOUBLE"}}}`
+	if string(b) != want {
+		t.Errorf("expected %v, got %v", want, string(b))
+	}
+
+	empty := newTestEntity(map[string]*aiplatformpb.FeatureValue{})
+	b, err = json.Marshal(empty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"entity_id":"123","entity_type":"my_entity","features":{}}` {
+		t.Errorf("metadata should be omitted, got %v", string(b))
+	}
+}
+
+func TestEntity_RoundTrip(t *testing.T) {
+	generated := time.Date(2023, 2, 16, 12, 0, 0, 0, time.UTC)
+	entity := newTestEntity(map[string]*aiplatformpb.FeatureValue{
+		"active":  {Value: &aiplatformpb.FeatureValue_BoolValue{BoolValue: true}},
+		"big":     {Value: &aiplatformpb.FeatureValue_Int64Value{Int64Value: 1<<62 + 1}},
+		"empty":   nil,
+		"prefs":   bytesFeature([]byte(`{"theme":"dark"}`)),
+		"segment": withGenerateTime(stringFeature("gold"), generated),
+		"spend":   {Value: &aiplatformpb.FeatureValue_DoubleValue{DoubleValue: 12.5}},
+		"score":   {Value: &aiplatformpb.FeatureValue_DoubleValue{DoubleValue: math.NaN()}},
+		"ratios": {Value: &aiplatformpb.FeatureValue_DoubleArrayValue{
+			DoubleArrayValue: &aiplatformpb.DoubleArray{Values: []float64{math.Inf(1), math.Inf(-1), 0.5}},
+		}},
+		"flags": {Value: &aiplatformpb.FeatureValue_BoolArrayValue{
+			BoolArrayValue: &aiplatformpb.BoolArray{Values: []bool{true, false}},
+		}},
+		"counts": {Value: &aiplatformpb.FeatureValue_Int64ArrayValue{
+			Int64ArrayValue: &aiplatformpb.Int64Array{Values: []int64{1, 2}},
+		}},
+		"weights": {Value: &aiplatformpb.FeatureValue_DoubleArrayValue{
+			DoubleArrayValue: &aiplatformpb.DoubleArray{Values: []float64{0.5}},
+		}},
+		"audiences": {Value: &aiplatformpb.FeatureValue_StringArrayValue{
+			StringArrayValue: &aiplatformpb.StringArray{Values: []string{"a", "b"}},
+		}},
+	})
+
+	type test struct {
+		name      string
+		marshal   func(e *Entity) ([]byte, error)
+		unmarshal func(e *Entity, b []byte) error
+	}
+	tests := []test{
+		{
+			name:      "binary",
+			marshal:   (*Entity).MarshalBinary,
+			unmarshal: (*Entity).UnmarshalBinary,
+		},
+		{
+			name: "json",
+			marshal: func(e *Entity) ([]byte, error) {
+				return json.Marshal(e)
+			},
+			unmarshal: func(e *Entity, b []byte) error {
+				return json.Unmarshal(b, e)
+			},
+		},
+	}
+	for _, tc := range tests {
+		b, err := tc.marshal(entity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.name == "json" && !strings.Contains(string(b), `"score":"NaN"`) {
+			t.Errorf("expected NaN to be encoded as a string: %s", b)
+		}
+		restored := &Entity{}
+		if err := tc.unmarshal(restored, b); err != nil {
+			t.Errorf("%v: %v", tc.name, err)
+			continue
+		}
+		if restored.ID != entity.ID || restored.header.GetEntityType() != entity.header.GetEntityType() ||
+			!reflect.DeepEqual(restored.FeatureIDs(), entity.FeatureIDs()) {
+			t.Errorf("%v: header was not restored: %v", tc.name, restored.header)
+		}
+		if diffs := Diff(entity, restored); len(diffs) != 0 {
+			t.Errorf("%v: restored entity differs:\n%v", tc.name, diffs)
+		}
+	}
+}
+
+func TestEntity_UnmarshalJSONErrors(t *testing.T) {
+	tests := []string{
+		`{"entity_id":"123","features":{"spend":12.5}}`,
+		`{"entity_id":"123","features":{"spend":"x"},"metadata":{"spend":{"value_type":"DOUBLE"}}}`,
+		`{"entity_id":"123","features":{"spend":1.5},"metadata":{"spend":{"value_type":"INT64"}}}`,
+	}
+	for _, in := range tests {
+		if err := json.Unmarshal([]byte(in), &Entity{}); err == nil {
+			t.Errorf("expected an error for %v", in)
+		}
+	}
+}
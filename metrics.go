@@ -0,0 +1,65 @@
+package vertigo
+
+import "sync"
+
+// MetricStaleFeatures counts feature values that were older than the maximum age allowed by
+// their FreshnessPolicy.
+const MetricStaleFeatures = "stale_features"
+
+// Metrics receives the counters emitted by the Client while reading features. Implementations
+// must be safe for concurrent use.
+type Metrics interface {
+	// Inc increments the counter called name for a feature of an entity type.
+	Inc(name, entityType, featureID string)
+}
+
+// nopMetrics discards every counter and is used when the Client has no Metrics configured.
+type nopMetrics struct{}
+
+func (nopMetrics) Inc(string, string, string) {}
+
+// CounterKey identifies a single counter tracked by Counters.
+type CounterKey struct {
+	Name       string
+	EntityType string
+	FeatureID  string
+}
+
+// Counters is an in-memory Metrics implementation, useful for exposing counts through an
+// existing metrics endpoint or asserting on them in tests.
+type Counters struct {
+	mu     sync.Mutex
+	counts map[CounterKey]int64
+}
+
+// NewCounters creates an empty set of Counters.
+func NewCounters() *Counters {
+	return &Counters{
+		counts: map[CounterKey]int64{},
+	}
+}
+
+// Inc increments the counter called name for a feature of an entity type.
+func (c *Counters) Inc(name, entityType, featureID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[CounterKey{Name: name, EntityType: entityType, FeatureID: featureID}]++
+}
+
+// Count returns the current value of a counter.
+func (c *Counters) Count(name, entityType, featureID string) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[CounterKey{Name: name, EntityType: entityType, FeatureID: featureID}]
+}
+
+// Snapshot returns a copy of every counter.
+func (c *Counters) Snapshot() map[CounterKey]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	snapshot := make(map[CounterKey]int64, len(c.counts))
+	for k, v := range c.counts {
+		snapshot[k] = v
+	}
+	return snapshot
+}
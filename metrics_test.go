@@ -0,0 +1,27 @@
+package vertigo
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestCounters(t *testing.T) {
+	c := NewCounters()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Inc(MetricStaleFeatures, "customer", "segment")
+		}()
+	}
+	wg.Wait()
+	c.Inc(MetricStaleFeatures, "customer", "spend")
+
+	if got := c.Count(MetricStaleFeatures, "customer", "segment"); got != 10 {
+		t.Errorf("expected 10, got %v", got)
+	}
+	if got := len(c.Snapshot()); got != 2 {
+		t.Errorf("expected 2 counters, got %v", got)
+	}
+}
@@ -3,20 +3,73 @@ package vertigo
 import (
 	"errors"
 	"reflect"
+	"strings"
+	"time"
 
 	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
 )
 
 const vertexTag = "vertex"
 
+// timestampOption marks a field that receives the generate time of a feature rather than its value.
+const timestampOption = "timestamp"
+
+// remainingOption marks the map[string]interface{} field that collects unmapped features.
+const remainingOption = "remaining"
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	remainingType = reflect.TypeOf(map[string]interface{}{})
+)
+
+// ErrUnmappedFeature is returned by ScanStruct in Strict mode when the Entity holds features
+// that are not mapped to any struct field.
+var ErrUnmappedFeature = errors.New("feature is not mapped to a struct field")
+
+// ScanOption configures how ScanStruct loads an Entity.
+type ScanOption func(o *scanOptions)
+
+type scanOptions struct {
+	strict bool
+}
+
+// Strict makes ScanStruct fail with ErrUnmappedFeature when a feature of the Entity is not
+// mapped to a struct field, unless the struct has a `vertex:",remaining"` field.
+func Strict() ScanOption {
+	return func(o *scanOptions) {
+		o.strict = true
+	}
+}
+
 // valueMapper is used to map struct field names to their field index, tag name, and type.
 type valueMapper struct {
 	fieldIdx    int
 	fieldName   string
 	vertexField string
+	options     tagOptions
 	t           reflect.Type
 }
 
+// tagOptions are the comma separated options that follow the feature ID in a vertex tag,
+// e.g. `vertex:"payload,json"`.
+type tagOptions []string
+
+// has reports whether opt is present in the tag options.
+func (o tagOptions) has(opt string) bool {
+	for _, v := range o {
+		if v == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTag splits a vertex tag into the feature ID and its options.
+func parseTag(tag string) (string, tagOptions) {
+	parts := strings.Split(tag, ",")
+	return parts[0], tagOptions(parts[1:])
+}
+
 // isStructPointer checks that interface x is a pointer to a struct type.
 func isStructPointer(dst interface{}) error {
 	t := reflect.TypeOf(dst)
@@ -99,24 +152,75 @@ func setStructField(fv *aiplatformpb.FeatureValue, structField reflect.Value) {
 	}
 }
 
+// setTimestampField sets a time.Time or *time.Time struct field to the generate time of fv.
+// The field is left untouched when fv carries no generate time.
+func setTimestampField(fv *aiplatformpb.FeatureValue, structField reflect.Value) error {
+	ts := fv.GetMetadata().GetGenerateTime()
+	if ts == nil {
+		return nil
+	}
+	gt := ts.AsTime()
+	switch structField.Type() {
+	case timeType:
+		structField.Set(reflect.ValueOf(gt))
+	case reflect.PtrTo(timeType):
+		structField.Set(reflect.ValueOf(&gt))
+	default:
+		return errors.New("timestamp fields must be of type time.Time or *time.Time")
+	}
+	return nil
+}
+
+// remainingField returns the map field tagged with the remaining option, allocating it when
+// nil. The returned Value is invalid when the struct has no such field.
+func remainingField(v reflect.Value, mapping map[string][]valueMapper) (reflect.Value, error) {
+	for _, lookup := range mapping[""] {
+		if !lookup.options.has(remainingOption) {
+			continue
+		}
+		if lookup.t != remainingType {
+			return reflect.Value{}, errors.New("remaining fields must be of type map[string]interface{}")
+		}
+		structField := extractStructField(v, lookup)
+		if structField.IsNil() {
+			structField.Set(reflect.MakeMap(remainingType))
+		}
+		return structField, nil
+	}
+	return reflect.Value{}, nil
+}
+
+// setRemaining stores the value of an unmapped feature in the remaining map. Features without
+// a value are stored as nil.
+func setRemaining(remaining reflect.Value, featureID string, fv *aiplatformpb.FeatureValue) {
+	elem := reflect.New(remainingType.Elem()).Elem()
+	if iv := valueFromProto(fv).Interface(); iv != nil {
+		elem.Set(reflect.ValueOf(iv))
+	}
+	remaining.SetMapIndex(reflect.ValueOf(featureID), elem)
+}
+
 // loadMap loads a vertex tag into it's respective field index, field name, and type from an interface.
-func loadMap(dst interface{}) map[string]valueMapper {
+// Several fields may share a feature ID, e.g. the value and its `timestamp`.
+func loadMap(dst interface{}) map[string][]valueMapper {
 	provided := reflect.ValueOf(dst)
 	ind := reflect.Indirect(provided)
 	providedType := ind.Type()
-	vm := map[string]valueMapper{}
+	vm := map[string][]valueMapper{}
 	for i := 0; i < ind.NumField(); i++ {
 		structField := providedType.Field(i)
 		tv, ok := structField.Tag.Lookup(vertexTag)
 		if !ok || tv == "-" {
 			continue
 		}
-		vm[tv] = valueMapper{
+		name, opts := parseTag(tv)
+		vm[name] = append(vm[name], valueMapper{
 			fieldIdx:    i,
 			t:           structField.Type,
 			fieldName:   structField.Name,
-			vertexField: tv,
-		}
+			vertexField: name,
+			options:     opts,
+		})
 	}
 	return vm
 }
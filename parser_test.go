@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
 	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
@@ -327,3 +328,44 @@ func TestExtractStructField(t *testing.T) {
 		})
 	}
 }
+
+func TestEntity_ScanStructRemaining(t *testing.T) {
+	entity := newTestEntity(map[string]*aiplatformpb.FeatureValue{
+		"segment":   stringFeature("gold"),
+		"new_field": stringFeature("surprise"),
+		"no_value":  nil,
+	})
+
+	type withRemaining struct {
+		Segment   string                 `vertex:"segment"`
+		Remaining map[string]interface{} `vertex:",remaining"`
+	}
+	w := withRemaining{}
+	if err := entity.ScanStruct(&w, Strict()); err != nil {
+		t.Fatal(err)
+	}
+	if w.Segment != "gold" || w.Remaining["new_field"] != "surprise" || len(w.Remaining) != 2 {
+		t.Errorf("remaining features were not collected: %+v", w)
+	}
+	if v, ok := w.Remaining["no_value"]; !ok || v != nil {
+		t.Errorf("expected no_value to be collected as nil, got %v, %v", v, ok)
+	}
+
+	type withoutRemaining struct {
+		Segment string `vertex:"segment"`
+	}
+	if err := entity.ScanStruct(&withoutRemaining{}); err != nil {
+		t.Errorf("unmapped features should be ignored outside of Strict mode: %v", err)
+	}
+	err := entity.ScanStruct(&withoutRemaining{}, Strict())
+	if !errors.Is(err, ErrUnmappedFeature) || !strings.Contains(err.Error(), "new_field, no_value") {
+		t.Errorf("expected ErrUnmappedFeature naming the unmapped features, got %v", err)
+	}
+
+	type badRemaining struct {
+		Remaining map[string]string `vertex:",remaining"`
+	}
+	if err := entity.ScanStruct(&badRemaining{}); err == nil {
+		t.Error("expected an error for a remaining field of the wrong type")
+	}
+}
@@ -1,26 +1,168 @@
 package vertigo
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+
 	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
 )
 
+// allFeatures is the feature selector that matches every feature of an entity type.
+const allFeatures = "*"
+
+// ErrUnknownFeature is returned by Query.Validate when a query references a feature that
+// does not exist in the schema.
+var ErrUnknownFeature = errors.New("feature does not exist")
+
 // Query represents a query to the Vertex AI Online Feature Store API for
 // getting an Entity's Feature Values.
 type Query struct {
 	EntityType string
 	EntityID   string
 	Features   []string
+
+	// Store is the name of the featurestore a Router sends the query to. When empty, the
+	// Router picks the store by entity type. It is ignored by Client.
+	Store string
+
+	// Caller identifies the service making the query, which the SensitivityPolicy of the entity
+	// type may allow to read restricted features.
+	Caller string
+
+	// resolved is set when Features already holds store feature IDs, see Client.prepareQuery.
+	resolved bool
+}
+
+// clone returns a copy of q that does not share its Features, for use after the caller may
+// have reused q.
+func (q *Query) clone() *Query {
+	c := *q
+	c.Features = append([]string(nil), q.Features...)
+	return &c
 }
 
 // BuildRequest translates the Query struct into an AI Platform ReadFeatureValuesRequest, which is submitted
 // to the Vertex AI Online Feature Store API to retrieve the Feature Values for an entity.
+// Restricted features the Caller is not allowed to read are left out, see CheckAccess, and
+// aliased feature names are replaced with their store feature IDs.
 func (q *Query) BuildRequest(cfg *Config) *aiplatformpb.ReadFeatureValuesRequest {
+	features := cfg.sensitivityPolicy(q.EntityType).permitted(q.Features, q.Caller)
+	if !q.resolved {
+		features = cfg.Aliases[q.EntityType].storeFeatureIDs(features)
+	}
 	return &aiplatformpb.ReadFeatureValuesRequest{
-		EntityType:      makeVertexEntityTypePath(cfg, q.EntityType),
-		EntityId:        q.EntityID,
-		FeatureSelector: &aiplatformpb.FeatureSelector{IdMatcher: &aiplatformpb.IdMatcher{Ids: q.Features}},
+		EntityType: makeVertexEntityTypePath(cfg, q.EntityType),
+		EntityId:   q.EntityID,
+		FeatureSelector: &aiplatformpb.FeatureSelector{IdMatcher: &aiplatformpb.IdMatcher{
+			Ids: features,
+		}},
+	}
+}
+
+// Validate checks the entity type and features of the query against schema. Unknown
+// features are reported in an error wrapping ErrUnknownFeature, with a suggestion for
+// features that look like a typo of an existing one.
+func (q *Query) Validate(schema *Schema) error {
+	if schema.EntityType != "" && q.EntityType != schema.EntityType {
+		return fmt.Errorf("query entity type %v does not match schema entity type %v", q.EntityType, schema.EntityType)
+	}
+
+	var unknown []string
+	for _, f := range q.Features {
+		if f == allFeatures {
+			continue
+		}
+		if _, ok := schema.Feature(f); ok {
+			continue
+		}
+		if suggestion, ok := suggest(f, schema.FeatureIDs()); ok {
+			unknown = append(unknown, fmt.Sprintf("%q (did you mean %q?)", f, suggestion))
+		} else {
+			unknown = append(unknown, fmt.Sprintf("%q", f))
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("%w in %v: %v", ErrUnknownFeature, q.EntityType, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// Expand returns a copy of the query with the "*" selector replaced by the explicit list of
+// features in schema, so the features that were read can be cached and audited.
+func (q *Query) Expand(schema *Schema) *Query {
+	expanded := &Query{
+		EntityType: q.EntityType,
+		EntityID:   q.EntityID,
+		Store:      q.Store,
+		Caller:     q.Caller,
+		resolved:   q.resolved,
+	}
+	seen := map[string]bool{}
+	add := func(ids ...string) {
+		for _, id := range ids {
+			if !seen[id] {
+				seen[id] = true
+				expanded.Features = append(expanded.Features, id)
+			}
+		}
+	}
+	for _, f := range q.Features {
+		if f == allFeatures {
+			add(schema.FeatureIDs()...)
+		} else {
+			add(f)
+		}
+	}
+	return expanded
+}
+
+// suggest returns the candidate closest to s by edit distance, if it is close enough to be
+// a plausible typo.
+func suggest(s string, candidates []string) (string, bool) {
+	best, bestDist := "", -1
+	for _, c := range candidates {
+		d := levenshtein(s, c)
+		if bestDist == -1 || d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	maxDist := len(s) / 3
+	if maxDist < 2 {
+		maxDist = 2
+	}
+	return best, bestDist != -1 && bestDist <= maxDist
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
 	}
+	return a
 }
 
 // makeVertexEntityTypePath builds the resource name for the specific entity being queried.
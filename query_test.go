@@ -1,6 +1,10 @@
 package vertigo
 
-import "testing"
+import (
+	"errors"
+	"strings"
+	"testing"
+)
 
 func TestQuery_BuildRequest(t *testing.T) {
 	q := &Query{
@@ -20,3 +24,74 @@ func TestQuery_BuildRequest(t *testing.T) {
 		t.Errorf("req was not built correctly: %v", req)
 	}
 }
+
+func TestQuery_Validate(t *testing.T) {
+	type test struct {
+		name     string
+		q        *Query
+		wantErr  bool
+		contains string
+	}
+
+	tests := []test{
+		{
+			name: "valid",
+			q:    &Query{EntityType: "my_customer", EntityID: "1", Features: []string{"segment", "*"}},
+		},
+		{
+			name:     "typo",
+			q:        &Query{EntityType: "my_customer", EntityID: "1", Features: []string{"segmnet"}},
+			wantErr:  true,
+			contains: `"segmnet" (did you mean "segment"?)`,
+		},
+		{
+			name:     "no suggestion",
+			q:        &Query{EntityType: "my_customer", EntityID: "1", Features: []string{"lifetime_value"}},
+			wantErr:  true,
+			contains: `"lifetime_value"`,
+		},
+		{
+			name:     "wrong entity type",
+			q:        &Query{EntityType: "my_product", EntityID: "1", Features: []string{"segment"}},
+			wantErr:  true,
+			contains: "does not match",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.q.Validate(testSchema())
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("%v: unexpected error %v", tc.name, err)
+			}
+			if err != nil && !strings.Contains(err.Error(), tc.contains) {
+				t.Errorf("%v: expected %q in %v", tc.name, tc.contains, err)
+			}
+		})
+	}
+
+	err := (&Query{EntityType: "my_customer", Features: []string{"segmnet"}}).Validate(testSchema())
+	if !errors.Is(err, ErrUnknownFeature) {
+		t.Errorf("expected ErrUnknownFeature, got %v", err)
+	}
+}
+
+func TestQuery_Expand(t *testing.T) {
+	q := &Query{EntityType: "my_customer", EntityID: "1", Features: []string{"segment", "*"}}
+	expanded := q.Expand(testSchema())
+	if len(expanded.Features) != len(testSchema().Features) || expanded.Features[0] != "segment" {
+		t.Errorf("unexpected expanded features: %v", expanded.Features)
+	}
+	if len(q.Features) != 2 {
+		t.Error("Expand should not modify the original query")
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	if d := levenshtein("segmnet", "segment"); d != 2 {
+		t.Errorf("expected 2, got %v", d)
+	}
+	if d := levenshtein("", "abc"); d != 3 {
+		t.Errorf("expected 3, got %v", d)
+	}
+}
@@ -0,0 +1,266 @@
+package vertigo
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+)
+
+var (
+	ErrNoFeatures    = errors.New("query does not select any features")
+	ErrMixedWildcard = errors.New(`query cannot mix "*" with explicit feature ids`)
+	ErrNoEntityIDs   = errors.New("query does not have any entity ids")
+)
+
+// BatchQuery represents a query for the Feature Values of several entities of the same
+// entity type, served by the StreamingReadFeatureValues RPC.
+type BatchQuery struct {
+	EntityType string
+	EntityIDs  []string
+	Features   []string
+
+	// Store is the name of the featurestore a Router sends the query to, as in Query.
+	Store string
+
+	// Caller identifies the service making the query, as in Query.
+	Caller string
+
+	// resolved is set when Features already holds store feature IDs, as in Query.
+	resolved bool
+}
+
+// BuildRequest translates the BatchQuery into an AI Platform StreamingReadFeatureValuesRequest.
+// Restricted features the Caller is not allowed to read are left out, and aliased feature names
+// are replaced with their store feature IDs.
+func (q *BatchQuery) BuildRequest(cfg *Config) *aiplatformpb.StreamingReadFeatureValuesRequest {
+	features := cfg.sensitivityPolicy(q.EntityType).permitted(q.Features, q.Caller)
+	if !q.resolved {
+		features = cfg.Aliases[q.EntityType].storeFeatureIDs(features)
+	}
+	return &aiplatformpb.StreamingReadFeatureValuesRequest{
+		EntityType: makeVertexEntityTypePath(cfg, q.EntityType),
+		EntityIds:  q.EntityIDs,
+		FeatureSelector: &aiplatformpb.FeatureSelector{IdMatcher: &aiplatformpb.IdMatcher{
+			Ids: features,
+		}},
+	}
+}
+
+// QueryBuilder provides a fluent interface for building a validated Query or BatchQuery.
+type QueryBuilder interface {
+	EntityType(entityType string) QueryBuilder
+	ID(entityID string) QueryBuilder
+	IDs(entityIDs ...string) QueryBuilder
+	Features(features ...string) QueryBuilder
+	FeaturesFrom(dst interface{}) QueryBuilder
+	FeaturesFromJoined(alias string, dst interface{}) QueryBuilder
+	Store(name string) QueryBuilder
+	Caller(name string) QueryBuilder
+	Build() (*Query, error)
+	BuildBatch() (*BatchQuery, error)
+}
+
+// queryBuilderFunc modifies a pointer to BatchQuery. Like builderFunc, the changes are lazily
+// evaluated when the query is built, which is also when any error they return is reported.
+type queryBuilderFunc func(q *BatchQuery) error
+
+type queryBuilder struct {
+	actions []queryBuilderFunc
+}
+
+// NewQuery returns a fluent API to build a Query or BatchQuery using the QueryBuilder interface.
+func NewQuery() QueryBuilder {
+	return &queryBuilder{
+		actions: []queryBuilderFunc{},
+	}
+}
+
+// EntityType sets the entity type ID of the query.
+func (b *queryBuilder) EntityType(entityType string) QueryBuilder {
+	b.actions = append(b.actions, func(q *BatchQuery) error {
+		q.EntityType = entityType
+		return nil
+	})
+	return b
+}
+
+// ID adds an entity ID to the query.
+func (b *queryBuilder) ID(entityID string) QueryBuilder {
+	return b.IDs(entityID)
+}
+
+// IDs adds several entity IDs to the query, for use with BuildBatch.
+func (b *queryBuilder) IDs(entityIDs ...string) QueryBuilder {
+	b.actions = append(b.actions, func(q *BatchQuery) error {
+		q.EntityIDs = append(q.EntityIDs, entityIDs...)
+		return nil
+	})
+	return b
+}
+
+// Features adds feature IDs, or the "*" selector, to the query.
+func (b *queryBuilder) Features(features ...string) QueryBuilder {
+	b.actions = append(b.actions, func(q *BatchQuery) error {
+		q.Features = append(q.Features, features...)
+		return nil
+	})
+	return b
+}
+
+// FeaturesFrom adds the feature IDs referenced by the `vertex` tags of dst, which must be a
+// pointer to a struct. Use FeaturesFromJoined for the composite structs of GetJoined.
+func (b *queryBuilder) FeaturesFrom(dst interface{}) QueryBuilder {
+	b.actions = append(b.actions, func(q *BatchQuery) error {
+		if err := isStructPointer(dst); err != nil {
+			return err
+		}
+		for _, id := range tagFeatureIDs(dst) {
+			if strings.Contains(id, ".") {
+				return fmt.Errorf("%w: tag %q has a join alias prefix, use FeaturesFromJoined", ErrInvalidFeatureID, id)
+			}
+			q.Features = append(q.Features, id)
+		}
+		return nil
+	})
+	return b
+}
+
+// FeaturesFromJoined adds the feature IDs referenced by the `vertex` tags of dst, a composite
+// struct scanned by JoinedEntity.ScanStruct, whose prefix is alias, the key of the query in
+// JoinQuery.Queries. The prefix is removed, e.g. "segment" is added for
+// `vertex:"customer.segment"` with the alias "customer", and the tags of other aliases are
+// ignored.
+func (b *queryBuilder) FeaturesFromJoined(alias string, dst interface{}) QueryBuilder {
+	b.actions = append(b.actions, func(q *BatchQuery) error {
+		if err := isStructPointer(dst); err != nil {
+			return err
+		}
+		for _, id := range tagFeatureIDs(dst) {
+			if featureID := strings.TrimPrefix(id, alias+"."); featureID != id {
+				q.Features = append(q.Features, featureID)
+			}
+		}
+		return nil
+	})
+	return b
+}
+
+// Store sets the name of the featurestore a Router sends the query to.
+func (b *queryBuilder) Store(name string) QueryBuilder {
+	b.actions = append(b.actions, func(q *BatchQuery) error {
+		q.Store = name
+		return nil
+	})
+	return b
+}
+
+// Caller sets the service making the query, see Query.Caller.
+func (b *queryBuilder) Caller(name string) QueryBuilder {
+	b.actions = append(b.actions, func(q *BatchQuery) error {
+		q.Caller = name
+		return nil
+	})
+	return b
+}
+
+// Build applies all the changes and validates a query for a single entity.
+func (b *queryBuilder) Build() (*Query, error) {
+	q, err := b.apply()
+	if err != nil {
+		return nil, err
+	}
+	if len(q.EntityIDs) != 1 {
+		return nil, fmt.Errorf("%w: Build requires exactly one entity id, got %v", ErrInvalidEntityID, len(q.EntityIDs))
+	}
+	return &Query{
+		EntityType: q.EntityType,
+		EntityID:   q.EntityIDs[0],
+		Features:   q.Features,
+		Store:      q.Store,
+		Caller:     q.Caller,
+	}, nil
+}
+
+// BuildBatch applies all the changes and validates a query for one or more entities.
+func (b *queryBuilder) BuildBatch() (*BatchQuery, error) {
+	return b.apply()
+}
+
+// apply runs the builder actions, de-duplicates entity and feature IDs, and validates the
+// syntax of every ID.
+func (b *queryBuilder) apply() (*BatchQuery, error) {
+	q := &BatchQuery{}
+	for _, a := range b.actions {
+		if err := a(q); err != nil {
+			return nil, err
+		}
+	}
+	q.EntityIDs = dedupe(q.EntityIDs)
+	q.Features = dedupe(q.Features)
+
+	if err := validateEntityTypeID(q.EntityType); err != nil {
+		return nil, err
+	}
+	if len(q.EntityIDs) == 0 {
+		return nil, ErrNoEntityIDs
+	}
+	for _, id := range q.EntityIDs {
+		if id == "" {
+			return nil, fmt.Errorf("%w: entity id must not be empty", ErrInvalidEntityID)
+		}
+	}
+	if len(q.Features) == 0 {
+		return nil, ErrNoFeatures
+	}
+	for _, f := range q.Features {
+		if f == allFeatures {
+			if len(q.Features) > 1 {
+				return nil, ErrMixedWildcard
+			}
+			continue
+		}
+		if err := validateFeatureID(f); err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+// tagFeatureIDs returns the sorted feature IDs referenced by the vertex tags of dst.
+func tagFeatureIDs(dst interface{}) []string {
+	var ids []string
+	for id := range loadMap(dst) {
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// dedupe removes repeated values from s, keeping the first occurrence of each.
+func dedupe(s []string) []string {
+	seen := map[string]bool{}
+	out := s[:0]
+	for _, v := range s {
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		out = append(out, v)
+	}
+	return out
+}
+
+// contains reports whether s holds v.
+func contains(s []string, v string) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
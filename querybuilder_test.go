@@ -0,0 +1,142 @@
+package vertigo
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestQueryBuilder_Build(t *testing.T) {
+	type customer struct {
+		Segment       string   `vertex:"segment"`
+		SixMonthSpend *float64 `vertex:"six_month_spend"`
+		Ignored       string   `vertex:"-"`
+	}
+
+	q, err := NewQuery().
+		EntityType("my_customer").
+		ID("123abc").
+		Features("segment", "market_audiences").
+		FeaturesFrom(&customer{}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"segment", "market_audiences", "six_month_spend"}
+	if q.EntityType != "my_customer" || q.EntityID != "123abc" || len(q.Features) != len(want) {
+		t.Fatalf("unexpected query: %+v", q)
+	}
+	for i, f := range want {
+		if q.Features[i] != f {
+			t.Errorf("feature %v: expected %v, got %v", i, f, q.Features[i])
+		}
+	}
+}
+
+func TestQueryBuilder_FeaturesFromJoined(t *testing.T) {
+	type prediction struct {
+		Segment  string  `vertex:"customer.segment"`
+		Price    float64 `vertex:"product.price"`
+		Spend    float64 `vertex:"customer.six_month_spend"`
+		Category string  `vertex:"product.category"`
+	}
+
+	q, err := NewQuery().
+		FeaturesFromJoined("customer", &prediction{}).
+		EntityType("my_customer").
+		ID("123abc").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"segment", "six_month_spend"}
+	if len(q.Features) != len(want) || q.Features[0] != want[0] || q.Features[1] != want[1] {
+		t.Errorf("expected %v, got %v", want, q.Features)
+	}
+
+	_, err = NewQuery().FeaturesFrom(&prediction{}).EntityType("my_customer").ID("123abc").Build()
+	if !errors.Is(err, ErrInvalidFeatureID) {
+		t.Errorf("expected ErrInvalidFeatureID from FeaturesFrom, got %v", err)
+	}
+}
+
+func TestQueryBuilder_Errors(t *testing.T) {
+	type test struct {
+		name string
+		b    QueryBuilder
+		err  error
+	}
+
+	tests := []test{
+		{
+			name: "invalid entity type",
+			b:    NewQuery().EntityType("My-Customer").ID("1").Features("*"),
+			err:  ErrInvalidEntityType,
+		},
+		{
+			name: "missing entity id",
+			b:    NewQuery().EntityType("my_customer").Features("*"),
+			err:  ErrNoEntityIDs,
+		},
+		{
+			name: "empty entity id",
+			b:    NewQuery().EntityType("my_customer").ID("").Features("*"),
+			err:  ErrInvalidEntityID,
+		},
+		{
+			name: "several entity ids",
+			b:    NewQuery().EntityType("my_customer").IDs("1", "2").Features("*"),
+			err:  ErrInvalidEntityID,
+		},
+		{
+			name: "no features",
+			b:    NewQuery().EntityType("my_customer").ID("1"),
+			err:  ErrNoFeatures,
+		},
+		{
+			name: "mixed wildcard",
+			b:    NewQuery().EntityType("my_customer").ID("1").Features("*", "segment"),
+			err:  ErrMixedWildcard,
+		},
+		{
+			name: "invalid feature id",
+			b:    NewQuery().EntityType("my_customer").ID("1").Features("Segment"),
+			err:  ErrInvalidFeatureID,
+		},
+		{
+			name: "features from non-pointer",
+			b:    NewQuery().EntityType("my_customer").ID("1").FeaturesFrom(struct{}{}),
+			err:  errors.New("dst must be a pointer"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.b.Build()
+			if err == nil || (!errors.Is(err, tc.err) && err.Error() != tc.err.Error()) {
+				t.Errorf("%v: expected %v, got %v", tc.name, tc.err, err)
+			}
+		})
+	}
+}
+
+func TestQueryBuilder_BuildBatch(t *testing.T) {
+	q, err := NewQuery().
+		EntityType("my_customer").
+		IDs("1", "2", "1").
+		ID("3").
+		Features("*").
+		BuildBatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(q.EntityIDs) != 3 || q.EntityIDs[2] != "3" {
+		t.Errorf("unexpected entity ids: %v", q.EntityIDs)
+	}
+
+	req := q.BuildRequest(&Config{ProjectID: "my-project", Region: nane, FeatureStoreName: "my_featurestore"})
+	if len(req.EntityIds) != 3 ||
+		req.EntityType != "projects/my-project/locations/northamerica-northeast1/featurestores/my_featurestore/entityTypes/my_customer" ||
+		req.FeatureSelector.IdMatcher.Ids[0] != "*" {
+		t.Errorf("req was not built correctly: %v", req)
+	}
+}
@@ -0,0 +1,138 @@
+package vertigo
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrInvalidEntityType   = errors.New("entity type id is not valid")
+	ErrInvalidEntityID     = errors.New("entity id is not valid")
+	ErrInvalidFeatureID    = errors.New("feature id is not valid")
+	ErrInvalidRegion       = errors.New("region is not valid")
+	ErrInvalidResourceName = errors.New("resource name is not valid")
+)
+
+// KnownRegions are the regions Vertex AI Feature Store is available in. See
+// `https://cloud.google.com/vertex-ai/docs/general/locations`. Use Config.AllowedRegions to
+// validate against another list.
+var KnownRegions = []string{
+	"us-central1", "us-east1", "us-east4", "us-south1", "us-west1", "us-west2", "us-west4",
+	"northamerica-northeast1", "northamerica-northeast2", "southamerica-east1",
+	"europe-central2", "europe-north1", "europe-west1", "europe-west2", "europe-west3",
+	"europe-west4", "europe-west6", "europe-west9", "me-west1",
+	"asia-east1", "asia-east2", "asia-northeast1", "asia-northeast2", "asia-northeast3",
+	"asia-south1", "asia-southeast1", "asia-southeast2", "australia-southeast1", "australia-southeast2",
+}
+
+var (
+	// projectIDPattern matches project IDs: 6 to 30 of [a-z0-9-], starting with a letter and not
+	// ending with a hyphen, optionally scoped by a domain as in "example.com:my-project", and the
+	// project numbers used in the resource names returned by the Vertex AI API.
+	projectIDPattern = regexp.MustCompile(`^(([a-z0-9.-]+:)?[a-z][a-z0-9-]{4,28}[a-z0-9]|[0-9]+)$`)
+
+	// featurestoreIDPattern matches featurestore IDs: up to 60 of [a-z0-9_], not starting with a digit.
+	featurestoreIDPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,59}$`)
+
+	// entityTypeIDPattern matches entity type IDs: up to 60 of [a-z0-9_], not starting with a digit.
+	entityTypeIDPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,59}$`)
+
+	// featureIDPattern matches feature IDs: up to 128 of [a-z0-9_], not starting with a digit.
+	featureIDPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,127}$`)
+)
+
+// validateEntityTypeID checks the syntax of an entity type ID.
+func validateEntityTypeID(id string) error {
+	if !entityTypeIDPattern.MatchString(id) {
+		return fmt.Errorf("%w: %q", ErrInvalidEntityType, id)
+	}
+	return nil
+}
+
+// validateFeatureID checks the syntax of a feature ID.
+func validateFeatureID(id string) error {
+	if !featureIDPattern.MatchString(id) {
+		return fmt.Errorf("%w: %q", ErrInvalidFeatureID, id)
+	}
+	return nil
+}
+
+// validateProjectID checks the syntax of a project ID.
+func validateProjectID(id string) error {
+	if !projectIDPattern.MatchString(id) {
+		return fmt.Errorf("%w: %q", ErrInvalidProjectID, id)
+	}
+	return nil
+}
+
+// validateRegion checks that region is one of allowed, or of KnownRegions when allowed is empty.
+func validateRegion(region string, allowed []string) error {
+	if len(allowed) == 0 {
+		allowed = KnownRegions
+	}
+	for _, r := range allowed {
+		if r == region {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q is not a known region", ErrInvalidRegion, region)
+}
+
+// validateFeaturestoreID checks the syntax of a featurestore ID.
+func validateFeaturestoreID(id string) error {
+	if !featurestoreIDPattern.MatchString(id) {
+		return fmt.Errorf("%w: %q", ErrInvalidFeatureStoreName, id)
+	}
+	return nil
+}
+
+// ParseResourceName parses the resource name of a featurestore, as returned by
+// Config.ParentPath, or of an entity type, e.g.
+// "projects/my-project/locations/us-east1/featurestores/my_featurestore/entityTypes/my_customer".
+// It returns the Config of the featurestore and the entity type ID, which is empty for a
+// featurestore resource name. Every segment is validated, and errors wrap ErrInvalidResourceName
+// for a malformed name or the error of the invalid segment, e.g. ErrInvalidRegion. The region
+// must be one of allowedRegions, or of KnownRegions when none are given, and allowedRegions
+// are kept as the AllowedRegions of the Config.
+func ParseResourceName(name string, allowedRegions ...string) (*Config, string, error) {
+	segments := strings.Split(name, "/")
+	if (len(segments) != 6 && len(segments) != 8) ||
+		segments[0] != "projects" || segments[2] != "locations" || segments[4] != "featurestores" ||
+		(len(segments) == 8 && segments[6] != "entityTypes") {
+		return nil, "", fmt.Errorf(
+			"%w: %q does not match projects/{project}/locations/{region}/featurestores/{featurestore}[/entityTypes/{entity_type}]",
+			ErrInvalidResourceName, name,
+		)
+	}
+
+	cfg := &Config{
+		ProjectID:        segments[1],
+		Region:           segments[3],
+		FeatureStoreName: segments[5],
+		AllowedRegions:   allowedRegions,
+	}
+	var entityType string
+	if len(segments) == 8 {
+		entityType = segments[7]
+	}
+
+	checks := []func() error{
+		func() error { return validateProjectID(cfg.ProjectID) },
+		func() error { return validateRegion(cfg.Region, cfg.AllowedRegions) },
+		func() error { return validateFeaturestoreID(cfg.FeatureStoreName) },
+		func() error {
+			if len(segments) == 8 {
+				return validateEntityTypeID(entityType)
+			}
+			return nil
+		},
+	}
+	for _, check := range checks {
+		if err := check(); err != nil {
+			return nil, "", fmt.Errorf("%w: in resource name %q", err, name)
+		}
+	}
+	return cfg, entityType, nil
+}
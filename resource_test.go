@@ -0,0 +1,108 @@
+package vertigo
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateResourceIDs(t *testing.T) {
+	type test struct {
+		id       string
+		validate func(string) error
+		err      error
+	}
+
+	tests := []test{
+		{id: "my_customer", validate: validateEntityTypeID, err: nil},
+		{id: "_private", validate: validateEntityTypeID, err: nil},
+		{id: "1customer", validate: validateEntityTypeID, err: ErrInvalidEntityType},
+		{id: "My-Customer", validate: validateEntityTypeID, err: ErrInvalidEntityType},
+		{id: strings.Repeat("a", 61), validate: validateEntityTypeID, err: ErrInvalidEntityType},
+		{id: "six_month_spend", validate: validateFeatureID, err: nil},
+		{id: strings.Repeat("a", 128), validate: validateFeatureID, err: nil},
+		{id: "", validate: validateFeatureID, err: ErrInvalidFeatureID},
+		{id: "*", validate: validateFeatureID, err: ErrInvalidFeatureID},
+	}
+
+	for _, tc := range tests {
+		if err := tc.validate(tc.id); !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+			t.Errorf("%q: expected %v, got %v", tc.id, tc.err, err)
+		}
+	}
+}
+
+func TestParseResourceName(t *testing.T) {
+	type test struct {
+		name       string
+		cfg        *Config
+		entityType string
+		err        error
+	}
+
+	tests := []test{
+		{
+			name:       "projects/my-project/locations/northamerica-northeast1/featurestores/my_featurestore/entityTypes/my_customer",
+			cfg:        &Config{ProjectID: "my-project", Region: nane, FeatureStoreName: "my_featurestore"},
+			entityType: "my_customer",
+		},
+		{
+			name: "projects/example.com:my-project/locations/us-east1/featurestores/my_featurestore",
+			cfg:  &Config{ProjectID: "example.com:my-project", Region: "us-east1", FeatureStoreName: "my_featurestore"},
+		},
+		{
+			name: "projects/123456789012/locations/us-east1/featurestores/my_featurestore",
+			cfg:  &Config{ProjectID: "123456789012", Region: "us-east1", FeatureStoreName: "my_featurestore"},
+		},
+		{name: "projects/my-project/locations/us-east1", err: ErrInvalidResourceName},
+		{name: "projects/my-project/regions/us-east1/featurestores/my_featurestore", err: ErrInvalidResourceName},
+		{name: "projects/my-project/locations/us-east1/featurestores/my_featurestore/entities/x", err: ErrInvalidResourceName},
+		{name: "projects/My_Project/locations/us-east1/featurestores/my_featurestore", err: ErrInvalidProjectID},
+		{name: "projects/my-project-/locations/us-east1/featurestores/my_featurestore", err: ErrInvalidProjectID},
+		{name: "projects/my-project/locations/us-centrl1/featurestores/my_featurestore", err: ErrInvalidRegion},
+		{name: "projects/my-project/locations/us-east1/featurestores/my-featurestore", err: ErrInvalidFeatureStoreName},
+		{name: "projects/my-project/locations/us-east1/featurestores/my_featurestore/entityTypes/1customer", err: ErrInvalidEntityType},
+	}
+
+	for _, tc := range tests {
+		cfg, entityType, err := ParseResourceName(tc.name)
+		if tc.err != nil {
+			if !errors.Is(err, tc.err) {
+				t.Errorf("%v: expected %v, got %v", tc.name, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", tc.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(cfg, tc.cfg) || entityType != tc.entityType {
+			t.Errorf("%v: got %+v and entity type %q", tc.name, cfg, entityType)
+		}
+		// ParseResourceName is the inverse of ParentPath and makeVertexEntityTypePath
+		path := cfg.ParentPath()
+		if entityType != "" {
+			path = makeVertexEntityTypePath(cfg, entityType)
+		}
+		if path != tc.name {
+			t.Errorf("%v: round trip produced %v", tc.name, path)
+		}
+	}
+}
+
+func TestParseResourceName_AllowedRegions(t *testing.T) {
+	name := "projects/my-project/locations/us-east1/featurestores/my_featurestore"
+	if _, _, err := ParseResourceName(name, "us-central1"); !errors.Is(err, ErrInvalidRegion) {
+		t.Errorf("expected ErrInvalidRegion, got %v", err)
+	}
+
+	name = "projects/my-project/locations/private-region1/featurestores/my_featurestore"
+	cfg, _, err := ParseResourceName(name, "private-region1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.AllowedRegions, []string{"private-region1"}) {
+		t.Errorf("expected the allowed regions to be kept, got %v", cfg.AllowedRegions)
+	}
+}
@@ -0,0 +1,187 @@
+package vertigo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	aiplatform "cloud.google.com/go/aiplatform/apiv1beta1"
+)
+
+var (
+	ErrUnknownStore = errors.New("featurestore is not registered")
+	ErrNoRoute      = errors.New("no featurestore is routed for the query")
+)
+
+// RouterConfig declares the featurestores of a Router and how queries are routed to them, e.g.
+// in YAML:
+//
+//	stores:
+//	  marketing: {project_id: marketing-prod, region: us-central1, feature_store_name: marketing}
+//	  risk: {project_id: risk-prod, region: us-central1, feature_store_name: risk}
+//	entity_types:
+//	  my_customer: marketing
+//	  transaction: risk
+type RouterConfig struct {
+	// Stores holds the Config of each featurestore, keyed by store name.
+	Stores map[string]*Config `json:"stores" yaml:"stores"`
+
+	// EntityTypes maps entity type IDs to the name of the store serving them.
+	EntityTypes map[string]string `json:"entity_types,omitempty" yaml:"entity_types,omitempty"`
+
+	// Default is the name of the store serving the entity types missing from EntityTypes.
+	// With a single store, it does not need to be set.
+	Default string `json:"default,omitempty" yaml:"default,omitempty"`
+}
+
+// validate checks that the routes reference registered stores and that every store resolves
+// to an endpoint.
+func (cfg RouterConfig) validate() error {
+	for name, storeCfg := range cfg.Stores {
+		if _, err := storeCfg.ResolveEndpoint(); err != nil {
+			return fmt.Errorf("store %v: %w", name, err)
+		}
+	}
+	for entityType, name := range cfg.EntityTypes {
+		if _, ok := cfg.Stores[name]; !ok {
+			return fmt.Errorf("%w: %q, routed for entity type %v", ErrUnknownStore, name, entityType)
+		}
+	}
+	if _, ok := cfg.Stores[cfg.Default]; cfg.Default != "" && !ok {
+		return fmt.Errorf("%w: default %q", ErrUnknownStore, cfg.Default)
+	}
+	return nil
+}
+
+// Router reads from several featurestores, possibly in different projects and regions, through
+// a Client per store. Clients of stores and replicas behind the same endpoint share a single
+// gRPC connection.
+type Router struct {
+	cfg     RouterConfig
+	clients map[string]*Client
+	conns   map[string]*aiplatform.FeaturestoreOnlineServingClient
+}
+
+// NewRouter creates a Router with a Client for each store of cfg, configured with opts.
+func NewRouter(ctx context.Context, cfg RouterConfig, opts ...ClientOption) (*Router, error) {
+	return newRouter(ctx, cfg, dialServing, opts...)
+}
+
+// newRouter creates a Router, creating the serving client of each distinct endpoint with dial.
+func newRouter(
+	ctx context.Context,
+	cfg RouterConfig,
+	dial func(ctx context.Context, endpoint string) (*aiplatform.FeaturestoreOnlineServingClient, error),
+	opts ...ClientOption,
+) (*Router, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	r := &Router{
+		cfg:     cfg,
+		clients: map[string]*Client{},
+		conns:   map[string]*aiplatform.FeaturestoreOnlineServingClient{},
+	}
+
+	names := make([]string, 0, len(cfg.Stores))
+	for name := range cfg.Stores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pooled := func(ctx context.Context, endpoint string) (*aiplatform.FeaturestoreOnlineServingClient, error) {
+		if conn, ok := r.conns[endpoint]; ok {
+			return conn, nil
+		}
+		conn, err := dial(ctx, endpoint)
+		if err != nil {
+			return nil, err
+		}
+		r.conns[endpoint] = conn
+		return conn, nil
+	}
+	for _, name := range names {
+		client, err := newClient(ctx, cfg.Stores[name], pooled, opts...)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("store %v: %w", name, err)
+		}
+		r.clients[name] = client
+	}
+	return r, nil
+}
+
+// Client returns the Client of the named store, e.g. to validate structs against its schemas.
+// It is closed by Router.Close and must not be closed by the caller.
+func (r *Router) Client(store string) (*Client, error) {
+	c, ok := r.clients[store]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownStore, store)
+	}
+	return c, nil
+}
+
+// route returns the Client of store, or the Client serving entityType when store is empty.
+func (r *Router) route(store, entityType string) (*Client, error) {
+	if store != "" {
+		return r.Client(store)
+	}
+	if name, ok := r.cfg.EntityTypes[entityType]; ok {
+		return r.Client(name)
+	}
+	if r.cfg.Default != "" {
+		return r.Client(r.cfg.Default)
+	}
+	if len(r.clients) == 1 {
+		for _, c := range r.clients {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: entity type %v", ErrNoRoute, entityType)
+}
+
+// GetEntity reads query with the Client of query.Store, or of the store serving its entity type.
+func (r *Router) GetEntity(ctx context.Context, query *Query) (*Entity, error) {
+	c, err := r.route(query.Store, query.EntityType)
+	if err != nil {
+		return nil, err
+	}
+	return c.GetEntity(ctx, query)
+}
+
+// GetEntities reads query with the Client of query.Store, or of the store serving its entity type.
+func (r *Router) GetEntities(ctx context.Context, query *BatchQuery) ([]*Entity, error) {
+	c, err := r.route(query.Store, query.EntityType)
+	if err != nil {
+		return nil, err
+	}
+	return c.GetEntities(ctx, query)
+}
+
+// GetJoined concurrently reads every query of q, each from the store it is routed to, as in
+// Client.GetJoined.
+func (r *Router) GetJoined(ctx context.Context, q JoinQuery) (*JoinedEntity, error) {
+	return getJoined(ctx, q, r.GetEntity)
+}
+
+// Close waits for the shadow reads in flight, flushes the feature log, then closes the clients
+// of every store and the shared gRPC connections.
+func (r *Router) Close() error {
+	var errs []error
+	for _, c := range r.clients {
+		c.shadow.wait()
+		c.featureLog.close()
+		if err := c.closeAdmin(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, conn := range r.conns {
+		if err := conn.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("closing router: %v", errs)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package vertigo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	aiplatform "cloud.google.com/go/aiplatform/apiv1beta1"
+	"google.golang.org/api/option"
+)
+
+func TestRouter(t *testing.T) {
+	cfg := RouterConfig{
+		Stores: map[string]*Config{
+			"marketing": {ProjectID: "marketing-prod", Region: "us-central1", FeatureStoreName: "marketing"},
+			"risk":      {ProjectID: "risk-prod", Region: "us-central1", FeatureStoreName: "risk"},
+			"europe":    {ProjectID: "marketing-prod", Region: "europe-west1", FeatureStoreName: "marketing_eu"},
+		},
+		EntityTypes: map[string]string{"transaction": "risk"},
+		Default:     "marketing",
+	}
+
+	dialed := map[string]int{}
+	dial := func(ctx context.Context, endpoint string) (*aiplatform.FeaturestoreOnlineServingClient, error) {
+		dialed[endpoint]++
+		return aiplatform.NewFeaturestoreOnlineServingClient(ctx, option.WithEndpoint(endpoint), option.WithoutAuthentication())
+	}
+	r, err := newRouter(context.Background(), cfg, dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// stores in the same region share a connection
+	if len(dialed) != 2 || dialed["us-central1-aiplatform.googleapis.com:443"] != 1 ||
+		r.clients["marketing"].v != r.clients["risk"].v || r.clients["marketing"].v == r.clients["europe"].v {
+		t.Errorf("connections were not shared by endpoint: %v", dialed)
+	}
+
+	type test struct {
+		store      string
+		entityType string
+		expected   string
+		err        error
+	}
+	tests := []test{
+		{entityType: "transaction", expected: "risk"},
+		{entityType: "my_customer", expected: "marketing"},
+		{store: "europe", entityType: "my_customer", expected: "europe"},
+		{store: "finance", entityType: "my_customer", err: ErrUnknownStore},
+	}
+	for _, tc := range tests {
+		c, err := r.route(tc.store, tc.entityType)
+		if tc.err != nil {
+			if !errors.Is(err, tc.err) {
+				t.Errorf("%v/%v: expected %v, got %v", tc.store, tc.entityType, tc.err, err)
+			}
+			continue
+		}
+		if err != nil || c != r.clients[tc.expected] {
+			t.Errorf("%v/%v: expected store %v, got %v", tc.store, tc.entityType, tc.expected, err)
+		}
+	}
+}
+
+func TestRouterConfig_Validate(t *testing.T) {
+	store := &Config{ProjectID: "my-project", Region: nane, FeatureStoreName: "my_featurestore"}
+	type test struct {
+		name string
+		cfg  RouterConfig
+		err  error
+	}
+	tests := []test{
+		{
+			name: "unknown entity type route",
+			cfg:  RouterConfig{Stores: map[string]*Config{"a": store}, EntityTypes: map[string]string{"x": "b"}},
+			err:  ErrUnknownStore,
+		},
+		{
+			name: "unknown default",
+			cfg:  RouterConfig{Stores: map[string]*Config{"a": store}, Default: "b"},
+			err:  ErrUnknownStore,
+		},
+		{
+			name: "invalid region",
+			cfg:  RouterConfig{Stores: map[string]*Config{"a": {ProjectID: "my-project", Region: "us-centrl1"}}},
+			err:  ErrInvalidRegion,
+		},
+		{
+			name: "valid",
+			cfg:  RouterConfig{Stores: map[string]*Config{"a": store}, Default: "a"},
+		},
+	}
+	for _, tc := range tests {
+		if err := tc.cfg.validate(); !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+			t.Errorf("%v: expected %v, got %v", tc.name, tc.err, err)
+		}
+	}
+}
+
+func TestRouter_CloseWaitsForShadowReads(t *testing.T) {
+	cfg := RouterConfig{Stores: map[string]*Config{
+		"marketing": {ProjectID: "marketing-prod", Region: "us-central1", FeatureStoreName: "marketing"},
+	}}
+	dial := func(ctx context.Context, endpoint string) (*aiplatform.FeaturestoreOnlineServingClient, error) {
+		return aiplatform.NewFeaturestoreOnlineServingClient(ctx, option.WithEndpoint(endpoint), option.WithoutAuthentication())
+	}
+	release := make(chan struct{})
+	r, err := newRouter(context.Background(), cfg, dial, WithShadow(
+		readerFunc(func(ctx context.Context, query *Query) (*Entity, error) {
+			<-release
+			return emptyEntity(query), nil
+		}),
+		ShadowOptions{Fraction: 1},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := &Query{EntityType: "my_customer", EntityID: "123"}
+	r.clients["marketing"].shadowRead(query, emptyEntity(query))
+
+	closed := make(chan struct{})
+	go func() {
+		r.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Error("Close returned before the shadow read was done")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-closed
+}
+
+func TestRouter_SharedFeatureLog(t *testing.T) {
+	cfg := RouterConfig{
+		Stores: map[string]*Config{
+			"marketing": {ProjectID: "marketing-prod", Region: "us-central1", FeatureStoreName: "marketing"},
+			"risk":      {ProjectID: "risk-prod", Region: "us-central1", FeatureStoreName: "risk"},
+		},
+		Default: "marketing",
+	}
+	dial := func(ctx context.Context, endpoint string) (*aiplatform.FeaturestoreOnlineServingClient, error) {
+		return aiplatform.NewFeaturestoreOnlineServingClient(ctx, option.WithEndpoint(endpoint), option.WithoutAuthentication())
+	}
+	var logged []string
+	r, err := newRouter(context.Background(), cfg, dial, WithFeatureLogger(loggerFunc(func(rec FeatureLogRecord) error {
+		// not synchronized: the race detector fails the test if Log is called concurrently
+		logged = append(logged, rec.Query.Store)
+		return nil
+	}), FeatureLogOptions{SampleRate: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.clients["marketing"].featureLog != r.clients["risk"].featureLog {
+		t.Fatal("expected the clients to share the feature log")
+	}
+
+	for _, store := range []string{"marketing", "risk", "marketing"} {
+		query := &Query{EntityType: "my_customer", EntityID: "123", Store: store}
+		r.clients[store].logEntity(context.Background(), query, emptyEntity(query))
+	}
+	r.Close()
+	if len(logged) != 3 {
+		t.Errorf("expected the buffered records to be flushed by Close, got %v", logged)
+	}
+}
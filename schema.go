@@ -0,0 +1,148 @@
+package vertigo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+	"google.golang.org/api/iterator"
+	"gopkg.in/yaml.v3"
+)
+
+// Schema describes the features of an entity type in the feature store.
+type Schema struct {
+	// Version is the snapshot format version, set by WriteSchema.
+	Version int `json:"version,omitempty" yaml:"version,omitempty"`
+
+	// EntityType is the entity type ID, e.g. "my_customer".
+	EntityType string `json:"entity_type" yaml:"entity_type"`
+
+	// Features are the feature definitions of the entity type.
+	Features []FeatureSchema `json:"features" yaml:"features"`
+}
+
+// FeatureSchema is the definition of a single feature.
+type FeatureSchema struct {
+	ID          string            `json:"id" yaml:"id"`
+	ValueType   ValueType         `json:"value_type" yaml:"value_type"`
+	Description string            `json:"description,omitempty" yaml:"description,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+}
+
+// Feature looks up the definition of featureID.
+func (s *Schema) Feature(featureID string) (FeatureSchema, bool) {
+	for _, f := range s.Features {
+		if f.ID == featureID {
+			return f, true
+		}
+	}
+	return FeatureSchema{}, false
+}
+
+// FeatureIDs returns the ID of every feature in the Schema.
+func (s *Schema) FeatureIDs() []string {
+	ids := make([]string, 0, len(s.Features))
+	for _, f := range s.Features {
+		ids = append(ids, f.ID)
+	}
+	return ids
+}
+
+// FetchSchema lists the feature definitions of entityType using the FeaturestoreService.
+func (c *Client) FetchSchema(ctx context.Context, entityType string) (*Schema, error) {
+	admin, err := c.featurestoreClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var features []*aiplatformpb.Feature
+	it := admin.ListFeatures(ctx, &aiplatformpb.ListFeaturesRequest{
+		Parent: makeVertexEntityTypePath(c.cfg, entityType),
+	})
+	for {
+		f, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ListFeatures: %v", err)
+		}
+		features = append(features, f)
+	}
+	return schemaFromFeatures(entityType, features), nil
+}
+
+// SchemaVersion is the version of the schema snapshot format written by WriteSchema.
+const SchemaVersion = 1
+
+// LoadSchema reads a Schema snapshot from a YAML (.yaml, .yml) or JSON file.
+func LoadSchema(filename string) (*Schema, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	s := &Schema{}
+	if isYAML(filename) {
+		err = yaml.Unmarshal(b, s)
+	} else {
+		err = json.Unmarshal(b, s)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", filename, err)
+	}
+	if s.Version > SchemaVersion {
+		return nil, fmt.Errorf("%v: unsupported schema version %v", filename, s.Version)
+	}
+	return s, nil
+}
+
+// WriteSchema writes a snapshot of s to a YAML (.yaml, .yml) or JSON file. Features are
+// sorted by ID so snapshots checked into version control produce minimal diffs.
+func WriteSchema(filename string, s *Schema) error {
+	snapshot := *s
+	snapshot.Version = SchemaVersion
+	snapshot.Features = append([]FeatureSchema(nil), s.Features...)
+	sort.Slice(snapshot.Features, func(i, j int) bool {
+		return snapshot.Features[i].ID < snapshot.Features[j].ID
+	})
+
+	var b []byte
+	var err error
+	if isYAML(filename) {
+		b, err = yaml.Marshal(&snapshot)
+	} else {
+		b, err = json.MarshalIndent(&snapshot, "", "  ")
+		b = append(b, '\n')
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, b, 0o644)
+}
+
+// isYAML reports whether filename has a YAML extension.
+func isYAML(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// schemaFromFeatures builds a Schema from the Feature resources returned by the
+// FeaturestoreService.
+func schemaFromFeatures(entityType string, features []*aiplatformpb.Feature) *Schema {
+	s := &Schema{EntityType: entityType}
+	for _, f := range features {
+		s.Features = append(s.Features, FeatureSchema{
+			ID:          path.Base(f.GetName()),
+			ValueType:   valueTypeFromProto(f.GetValueType()),
+			Description: f.GetDescription(),
+			Labels:      f.GetLabels(),
+		})
+	}
+	return s
+}
@@ -0,0 +1,91 @@
+package vertigo
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+)
+
+func TestSchemaFromFeatures(t *testing.T) {
+	s := schemaFromFeatures("my_customer", []*aiplatformpb.Feature{
+		{
+			Name:        "projects/p/locations/l/featurestores/fs/entityTypes/my_customer/features/segment",
+			ValueType:   aiplatformpb.Feature_STRING,
+			Description: "customer segment",
+			Labels:      map[string]string{"owner": "marketing"},
+		},
+		{
+			Name:      "projects/p/locations/l/featurestores/fs/entityTypes/my_customer/features/six_month_spend",
+			ValueType: aiplatformpb.Feature_DOUBLE,
+		},
+	})
+
+	f, ok := s.Feature("segment")
+	if !ok || f.ValueType != StringType || f.Description != "customer segment" || f.Labels["owner"] != "marketing" {
+		t.Errorf("unexpected segment schema: %+v", f)
+	}
+	if ids := s.FeatureIDs(); len(ids) != 2 || ids[1] != "six_month_spend" {
+		t.Errorf("unexpected feature IDs: %v", ids)
+	}
+	if _, ok := s.Feature("missing"); ok {
+		t.Error("expected missing feature to be absent")
+	}
+}
+
+func TestLoadSchema(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "schema.json")
+	b, err := json.Marshal(testSchema())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"value_type":"STRING_ARRAY"`) {
+		t.Errorf("value types should be encoded by name: %v", string(b))
+	}
+	if err := os.WriteFile(filename, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := LoadSchema(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s, testSchema()) {
+		t.Errorf("schema did not round trip: %+v", s)
+	}
+
+	if err := os.WriteFile(filename, []byte(`{"features":[{"id":"x","value_type":"FLOAT"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSchema(filename); err == nil {
+		t.Error("expected an error for an unknown value type")
+	}
+}
+
+func TestWriteSchema(t *testing.T) {
+	for _, name := range []string{"schema.yaml", "schema.json"} {
+		t.Run(name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), name)
+			if err := WriteSchema(filename, testSchema()); err != nil {
+				t.Fatal(err)
+			}
+			s, err := LoadSchema(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.Version != SchemaVersion || s.EntityType != "my_customer" || len(s.Features) != len(testSchema().Features) {
+				t.Errorf("unexpected snapshot: %+v", s)
+			}
+			if s.Features[0].ID != "another_numeric_feature" || s.Features[0].ValueType != Int64Type {
+				t.Errorf("features should be sorted by ID: %+v", s.Features[0])
+			}
+			if len(DiffSchemas(testSchema(), s)) != 0 {
+				t.Errorf("snapshot did not round trip: %v", DiffSchemas(testSchema(), s))
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package vertigo
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ChangeKind classifies a difference between two schemas.
+type ChangeKind int
+
+const (
+	// FeatureAdded is a feature that only exists in the new schema.
+	FeatureAdded ChangeKind = iota
+	// FeatureRemoved is a feature that only exists in the old schema. It is a breaking change.
+	FeatureRemoved
+	// ValueTypeChanged is a feature whose value type differs. It is a breaking change.
+	ValueTypeChanged
+	// DescriptionChanged is a feature whose description differs.
+	DescriptionChanged
+	// LabelsChanged is a feature whose labels differ.
+	LabelsChanged
+)
+
+var changeKindNames = map[ChangeKind]string{
+	FeatureAdded:       "added",
+	FeatureRemoved:     "removed",
+	ValueTypeChanged:   "value type changed",
+	DescriptionChanged: "description changed",
+	LabelsChanged:      "labels changed",
+}
+
+func (k ChangeKind) String() string {
+	return changeKindNames[k]
+}
+
+// SchemaChange is a single difference between two schemas.
+type SchemaChange struct {
+	Kind      ChangeKind
+	FeatureID string
+	Old       FeatureSchema
+	New       FeatureSchema
+}
+
+// Breaking reports whether the change can break readers of the old schema.
+func (c SchemaChange) Breaking() bool {
+	return c.Kind == FeatureRemoved || c.Kind == ValueTypeChanged
+}
+
+func (c SchemaChange) String() string {
+	switch c.Kind {
+	case FeatureAdded:
+		return fmt.Sprintf("+ %v (%v)", c.FeatureID, c.New.ValueType)
+	case FeatureRemoved:
+		return fmt.Sprintf("- %v (%v)", c.FeatureID, c.Old.ValueType)
+	case ValueTypeChanged:
+		return fmt.Sprintf("~ %v: %v -> %v", c.FeatureID, c.Old.ValueType, c.New.ValueType)
+	case DescriptionChanged:
+		return fmt.Sprintf("~ %v: description %q -> %q", c.FeatureID, c.Old.Description, c.New.Description)
+	}
+	return fmt.Sprintf("~ %v: labels %v -> %v", c.FeatureID, c.Old.Labels, c.New.Labels)
+}
+
+// SchemaChanges is the list of differences between two schemas.
+type SchemaChanges []SchemaChange
+
+// Breaking returns the breaking changes.
+func (cs SchemaChanges) Breaking() SchemaChanges {
+	var breaking SchemaChanges
+	for _, c := range cs {
+		if c.Breaking() {
+			breaking = append(breaking, c)
+		}
+	}
+	return breaking
+}
+
+func (cs SchemaChanges) String() string {
+	lines := make([]string, 0, len(cs))
+	for _, c := range cs {
+		lines = append(lines, c.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// DiffSchemas compares the features of old and new, ordered by feature ID.
+func DiffSchemas(old, new *Schema) SchemaChanges {
+	var changes SchemaChanges
+	for _, o := range old.Features {
+		n, ok := new.Feature(o.ID)
+		if !ok {
+			changes = append(changes, SchemaChange{Kind: FeatureRemoved, FeatureID: o.ID, Old: o})
+			continue
+		}
+		if o.ValueType != n.ValueType {
+			changes = append(changes, SchemaChange{Kind: ValueTypeChanged, FeatureID: o.ID, Old: o, New: n})
+		}
+		if o.Description != n.Description {
+			changes = append(changes, SchemaChange{Kind: DescriptionChanged, FeatureID: o.ID, Old: o, New: n})
+		}
+		if len(o.Labels)+len(n.Labels) > 0 && !reflect.DeepEqual(o.Labels, n.Labels) {
+			changes = append(changes, SchemaChange{Kind: LabelsChanged, FeatureID: o.ID, Old: o, New: n})
+		}
+	}
+	for _, n := range new.Features {
+		if _, ok := old.Feature(n.ID); !ok {
+			changes = append(changes, SchemaChange{Kind: FeatureAdded, FeatureID: n.ID, New: n})
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].FeatureID < changes[j].FeatureID
+	})
+	return changes
+}
@@ -0,0 +1,43 @@
+package vertigo
+
+import "testing"
+
+func TestDiffSchemas(t *testing.T) {
+	old := testSchema()
+	new := &Schema{
+		EntityType: "my_customer",
+		Features: []FeatureSchema{
+			{ID: "segment", ValueType: StringType, Description: "customer segment"},
+			{ID: "market_audiences", ValueType: StringArrayType},
+			{ID: "six_month_spend", ValueType: Int64Type},
+			{ID: "preferences", ValueType: BytesType, Labels: map[string]string{"pii": "true"}},
+			{ID: "spend_6m", ValueType: DoubleType},
+		},
+	}
+
+	changes := DiffSchemas(old, new)
+	want := []struct {
+		kind ChangeKind
+		id   string
+	}{
+		{FeatureRemoved, "another_numeric_feature"},
+		{LabelsChanged, "preferences"},
+		{DescriptionChanged, "segment"},
+		{ValueTypeChanged, "six_month_spend"},
+		{FeatureAdded, "spend_6m"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("expected %v changes, got:\n%v", len(want), changes)
+	}
+	for i, w := range want {
+		if changes[i].Kind != w.kind || changes[i].FeatureID != w.id {
+			t.Errorf("change %v: expected %v %v, got %v", i, w.kind, w.id, changes[i])
+		}
+	}
+	if breaking := changes.Breaking(); len(breaking) != 2 {
+		t.Errorf("expected 2 breaking changes, got:\n%v", breaking)
+	}
+	if len(DiffSchemas(old, testSchema())) != 0 {
+		t.Error("identical schemas should not differ")
+	}
+}
@@ -0,0 +1,291 @@
+package vertigo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+)
+
+// ErrAccessDenied is returned when a query requests restricted features its Caller is not
+// allowed to read.
+var ErrAccessDenied = errors.New("caller is not allowed to request the feature")
+
+// ErrInvalidSensitivityPolicy is returned by Config validation when a SensitivityPolicy names
+// an invalid feature ID, or names a store feature ID instead of its alias.
+var ErrInvalidSensitivityPolicy = errors.New("sensitivity policy is not valid")
+
+// SensitivityLabel is the feature label marking a feature as sensitive, e.g. "sensitivity: pii".
+// Any value other than "public" makes the feature sensitive, see Schema.SensitiveFeatures.
+// Labels apply once the Client has the schema, see WithSensitivityLabels.
+const SensitivityLabel = "sensitivity"
+
+// redacted replaces sensitive values in output.
+const redacted = "[REDACTED]"
+
+// SensitivityPolicy declares the sensitive and restricted features of an entity type, e.g. in
+// YAML:
+//
+//	sensitivity:
+//	  my_customer:
+//	    sensitive: [email_hash, geo]
+//	    restricted:
+//	      email_hash: [fraud-service]
+type SensitivityPolicy struct {
+	// Sensitive features are redacted wherever vertigo logs, prints or serializes entities:
+	// Entity.MarshalJSON, MarshalBinary and fmt output, FeatureDiff reports, FeatureLogger records
+	// and scan errors. Their values are still available through ScanStruct, Value and ToMap.
+	Sensitive []string `json:"sensitive,omitempty" yaml:"sensitive,omitempty"`
+
+	// Restricted maps feature IDs to the callers allowed to request them, see Query.Caller.
+	// Other callers, including queries without a Caller, cannot read the feature.
+	Restricted map[string][]string `json:"restricted,omitempty" yaml:"restricted,omitempty"`
+}
+
+// validate checks the syntax of the feature IDs of the policy, and that features with an alias
+// are named by their alias, so every name of a feature gets the same policy.
+func (p SensitivityPolicy) validate(aliases Aliases) error {
+	ids := append([]string{}, p.Sensitive...)
+	for id := range p.Restricted {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if err := validateFeatureID(id); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSensitivityPolicy, err)
+		}
+		if _, ok := aliases[id]; ok {
+			continue
+		}
+		for _, name := range aliases.names(id) {
+			return fmt.Errorf("%w: %v is the store feature of alias %v, use the alias", ErrInvalidSensitivityPolicy, id, name)
+		}
+	}
+	return nil
+}
+
+// resolve returns the policy with every feature also listed under its other names: the store
+// feature IDs of an alias, and the aliases of a store feature ID. When several names of a
+// feature are restricted, only the callers allowed by all of them are.
+func (p SensitivityPolicy) resolve(aliases Aliases) SensitivityPolicy {
+	if len(aliases) == 0 {
+		return p
+	}
+	resolved := SensitivityPolicy{}
+	for _, id := range p.Sensitive {
+		resolved.Sensitive = append(resolved.Sensitive, aliases.equivalents(id)...)
+	}
+	for id, callers := range p.Restricted {
+		if resolved.Restricted == nil {
+			resolved.Restricted = map[string][]string{}
+		}
+		for _, eq := range aliases.equivalents(id) {
+			if existing, ok := resolved.Restricted[eq]; ok {
+				resolved.Restricted[eq] = intersect(existing, callers)
+			} else {
+				resolved.Restricted[eq] = callers
+			}
+		}
+	}
+	return resolved
+}
+
+// intersect returns the values of a that are also in b.
+func intersect(a, b []string) []string {
+	out := []string{}
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				out = append(out, x)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// sensitivityPolicy returns the SensitivityPolicy of entityType, resolved through its aliases.
+func (c *Config) sensitivityPolicy(entityType string) SensitivityPolicy {
+	return c.Sensitivity[entityType].resolve(c.Aliases[entityType])
+}
+
+// allowed reports whether caller may read featureID.
+func (p SensitivityPolicy) allowed(featureID, caller string) bool {
+	callers, restricted := p.Restricted[featureID]
+	if !restricted {
+		return true
+	}
+	for _, c := range callers {
+		if c == caller && caller != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// permitted returns the features caller may read. The "*" selector is kept, the restricted
+// features it matches are removed from the Entity once read.
+func (p SensitivityPolicy) permitted(features []string, caller string) []string {
+	if len(p.Restricted) == 0 {
+		return features
+	}
+	out := make([]string, 0, len(features))
+	for _, f := range features {
+		if p.allowed(f, caller) {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// CheckAccess returns an error wrapping ErrAccessDenied when the query explicitly requests
+// features of the SensitivityPolicy of its entity type that its Caller is not allowed to read.
+// Restricted features matched by the "*" selector are silently left out of the Entity instead.
+// Aliases and their store feature IDs are checked alike.
+func (q *Query) CheckAccess(cfg *Config) error {
+	policy := cfg.sensitivityPolicy(q.EntityType)
+	var denied []string
+	for _, f := range q.Features {
+		if !policy.allowed(f, q.Caller) {
+			denied = append(denied, f)
+		}
+	}
+	if len(denied) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: caller %q, %v.%v", ErrAccessDenied, q.Caller, q.EntityType, strings.Join(denied, ", "))
+}
+
+// SensitiveFeatures returns the sorted IDs of the features labelled with a SensitivityLabel
+// other than "public".
+func (s *Schema) SensitiveFeatures() []string {
+	var ids []string
+	for _, f := range s.Features {
+		if label, ok := f.Labels[SensitivityLabel]; ok && label != "public" {
+			ids = append(ids, f.ID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// sensitiveFeatures returns the sensitive features of entityType under all their names, from the
+// Config and from the labels of its schema when the Client has it, see WithSensitivityLabels.
+func (c *Client) sensitiveFeatures(entityType string) map[string]bool {
+	sensitive := map[string]bool{}
+	for _, id := range c.cfg.sensitivityPolicy(entityType).Sensitive {
+		sensitive[id] = true
+	}
+	c.schemaMu.Lock()
+	schema, ok := c.schemas[entityType]
+	c.schemaMu.Unlock()
+	if ok {
+		for _, id := range schema.SensitiveFeatures() {
+			for _, eq := range c.cfg.Aliases[entityType].equivalents(id) {
+				sensitive[eq] = true
+			}
+		}
+	}
+	return sensitive
+}
+
+// loadSensitivityLabels caches the schema of entityType, whose labels mark sensitive features,
+// when WithSensitivityLabels is set.
+func (c *Client) loadSensitivityLabels(ctx context.Context, entityType string) error {
+	if !c.sensitivityLabels {
+		return nil
+	}
+	_, err := c.schema(ctx, entityType)
+	return err
+}
+
+// Sensitive reports whether featureID is sensitive, in which case its value is redacted from
+// the output of the Entity.
+func (e *Entity) Sensitive(featureID string) bool {
+	return e.sensitive[featureID]
+}
+
+// Format implements fmt.Formatter, so printing an Entity with the fmt package shows its feature
+// values with the values of sensitive features redacted, e.g. "123 {email_hash: [REDACTED]}".
+func (e *Entity) Format(f fmt.State, verb rune) {
+	features := make([]string, 0, len(e.header.GetFeatureDescriptors()))
+	for _, id := range e.FeatureIDs() {
+		v, _ := e.Value(id)
+		s := v.String()
+		if e.Sensitive(id) {
+			s = redacted
+		}
+		features = append(features, fmt.Sprintf("%v: %v", id, s))
+	}
+	fmt.Fprintf(f, "%v {%v}", e.ID, strings.Join(features, ", "))
+}
+
+// restrict removes the features of e that caller is not allowed to read.
+func restrict(e *Entity, policy SensitivityPolicy, caller string) {
+	if len(policy.Restricted) == 0 {
+		return
+	}
+	header := &aiplatformpb.ReadFeatureValuesResponse_Header{EntityType: e.header.GetEntityType()}
+	var data []*aiplatformpb.ReadFeatureValuesResponse_EntityView_Data
+	for i, fd := range e.header.GetFeatureDescriptors() {
+		if !policy.allowed(fd.Id, caller) {
+			continue
+		}
+		header.FeatureDescriptors = append(header.FeatureDescriptors, fd)
+		data = append(data, e.data[i])
+	}
+	e.header, e.data = header, data
+}
+
+// markSensitive marks featureID as sensitive.
+func (e *Entity) markSensitive(featureID string) {
+	if e.sensitive == nil {
+		e.sensitive = map[string]bool{}
+	}
+	e.sensitive[featureID] = true
+}
+
+// redactedData returns the data of e with the values of sensitive features replaced by an empty
+// value list, which the feature store does not return for a single value, so the redaction
+// survives MarshalBinary, see isRedacted.
+func (e *Entity) redactedData() []*aiplatformpb.ReadFeatureValuesResponse_EntityView_Data {
+	if len(e.sensitive) == 0 {
+		return e.data
+	}
+	data := make([]*aiplatformpb.ReadFeatureValuesResponse_EntityView_Data, len(e.data))
+	for i, fd := range e.header.GetFeatureDescriptors() {
+		if i >= len(e.data) {
+			break
+		}
+		data[i] = e.data[i]
+		if e.Sensitive(fd.Id) {
+			data[i] = &aiplatformpb.ReadFeatureValuesResponse_EntityView_Data{
+				Data: &aiplatformpb.ReadFeatureValuesResponse_EntityView_Data_Values{Values: &aiplatformpb.FeatureValueList{}},
+			}
+		}
+	}
+	return data
+}
+
+// isRedacted reports whether d is the data of a sensitive feature encoded by redactedData.
+func isRedacted(d *aiplatformpb.ReadFeatureValuesResponse_EntityView_Data) bool {
+	values := d.GetValues()
+	return values != nil && len(values.GetValues()) == 0
+}
+
+// redactedError hides the message of an error about a sensitive feature, which may quote its
+// value, while errors.Is and errors.As still match it.
+type redactedError struct {
+	err error
+}
+
+func (e redactedError) Error() string {
+	return "value cannot be scanned " + redacted
+}
+
+func (e redactedError) Unwrap() error {
+	return e.err
+}
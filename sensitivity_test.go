@@ -0,0 +1,386 @@
+package vertigo
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+)
+
+func testSensitivityConfig() *Config {
+	return &Config{
+		ProjectID:        "my-project",
+		Region:           nane,
+		FeatureStoreName: "my_featurestore",
+		Sensitivity: map[string]SensitivityPolicy{
+			"my_customer": {
+				Sensitive:  []string{"email_hash", "geo"},
+				Restricted: map[string][]string{"email_hash": {"fraud-service"}},
+			},
+		},
+	}
+}
+
+func TestQuery_CheckAccess(t *testing.T) {
+	type test struct {
+		name     string
+		features []string
+		caller   string
+		denied   bool
+	}
+	tests := []test{
+		{name: "unrestricted", features: []string{"segment", "geo"}},
+		{name: "allowed caller", features: []string{"email_hash"}, caller: "fraud-service"},
+		{name: "other caller", features: []string{"segment", "email_hash"}, caller: "ads-service", denied: true},
+		{name: "no caller", features: []string{"email_hash"}, denied: true},
+		{name: "all features", features: []string{"*"}},
+	}
+	cfg := testSensitivityConfig()
+	for _, tc := range tests {
+		q := &Query{EntityType: "my_customer", EntityID: "123", Features: tc.features, Caller: tc.caller}
+		err := q.CheckAccess(cfg)
+		if tc.denied != errors.Is(err, ErrAccessDenied) {
+			t.Errorf("%v: unexpected error %v", tc.name, err)
+		}
+	}
+}
+
+func TestQuery_BuildRequestRestricted(t *testing.T) {
+	type test struct {
+		caller   string
+		expected []string
+	}
+	tests := []test{
+		{caller: "", expected: []string{"segment"}},
+		{caller: "ads-service", expected: []string{"segment"}},
+		{caller: "fraud-service", expected: []string{"segment", "email_hash"}},
+	}
+	cfg := testSensitivityConfig()
+	for _, tc := range tests {
+		q := &Query{EntityType: "my_customer", EntityID: "123", Features: []string{"segment", "email_hash"}, Caller: tc.caller}
+		ids := q.BuildRequest(cfg).FeatureSelector.IdMatcher.Ids
+		if !reflect.DeepEqual(ids, tc.expected) {
+			t.Errorf("caller %q: expected %v, got %v", tc.caller, tc.expected, ids)
+		}
+
+		bq := &BatchQuery{EntityType: "my_customer", EntityIDs: []string{"123"}, Features: q.Features, Caller: tc.caller}
+		ids = bq.BuildRequest(cfg).FeatureSelector.IdMatcher.Ids
+		if !reflect.DeepEqual(ids, tc.expected) {
+			t.Errorf("batch caller %q: expected %v, got %v", tc.caller, tc.expected, ids)
+		}
+	}
+}
+
+func TestRestrict(t *testing.T) {
+	policy := testSensitivityConfig().Sensitivity["my_customer"]
+	values := map[string]*aiplatformpb.FeatureValue{
+		"email_hash": stringFeature("a1b2"),
+		"segment":    stringFeature("gold"),
+	}
+
+	e := newTestEntity(values)
+	restrict(e, policy, "ads-service")
+	if !reflect.DeepEqual(e.FeatureIDs(), []string{"segment"}) {
+		t.Errorf("expected email_hash to be removed, got %v", e.FeatureIDs())
+	}
+	if s, _ := e.String("segment"); s != "gold" {
+		t.Errorf("expected gold, got %v", s)
+	}
+
+	e = newTestEntity(values)
+	restrict(e, policy, "fraud-service")
+	if !e.Has("email_hash") {
+		t.Error("expected fraud-service to read email_hash")
+	}
+}
+
+func newSensitiveEntity() *Entity {
+	e := newTestEntity(map[string]*aiplatformpb.FeatureValue{
+		"email_hash": stringFeature("a1b2"),
+		"segment":    stringFeature("gold"),
+	})
+	e.sensitive = map[string]bool{"email_hash": true}
+	return e
+}
+
+func TestEntity_Redaction(t *testing.T) {
+	e := newSensitiveEntity()
+
+	if s := fmt.Sprint(e); s != "123 {email_hash: [REDACTED], segment: gold}" {
+		t.Errorf("unexpected fmt output: %v", s)
+	}
+
+	b, err := e.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "a1b2") {
+		t.Errorf("sensitive value in JSON: %s", b)
+	}
+	decoded := &Entity{}
+	if err := decoded.UnmarshalJSON(b); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Sensitive("email_hash") || decoded.Has("email_hash") {
+		t.Errorf("expected email_hash to stay redacted, got %v", decoded)
+	}
+
+	b, err = e.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "a1b2") {
+		t.Errorf("sensitive value in binary encoding: %q", b)
+	}
+	decoded = &Entity{}
+	if err := decoded.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Sensitive("email_hash") || decoded.Has("email_hash") || decoded.Sensitive("segment") {
+		t.Errorf("expected email_hash to stay redacted in the binary encoding, got %v", decoded)
+	}
+	if s, ok := decoded.String("segment"); !ok || s != "gold" {
+		t.Errorf("expected gold, got %v", s)
+	}
+
+	if s, ok := e.String("email_hash"); !ok || s != "a1b2" {
+		t.Errorf("expected the value to stay readable, got %v", s)
+	}
+}
+
+func TestEntity_ScanStructRedactedError(t *testing.T) {
+	type customer struct {
+		EmailHash map[string]string `vertex:"email_hash,json"`
+	}
+	err := newSensitiveEntity().ScanStruct(&customer{})
+	if err == nil {
+		t.Fatal("expected a scan error")
+	}
+	if strings.Contains(err.Error(), "'a'") || !strings.Contains(err.Error(), "email_hash") {
+		t.Errorf("unexpected error message: %v", err)
+	}
+	var redactedErr redactedError
+	if !errors.As(err, &redactedErr) {
+		t.Errorf("expected a redactedError, got %T", err)
+	}
+}
+
+func TestDiff_Sensitive(t *testing.T) {
+	a := newSensitiveEntity()
+	b := newTestEntity(map[string]*aiplatformpb.FeatureValue{
+		"email_hash": stringFeature("c3d4"),
+		"segment":    stringFeature("silver"),
+	})
+
+	diffs := Diff(a, b, IgnoreGenerateTime())
+	if len(diffs) != 2 {
+		t.Fatalf("expected 2 diffs, got %v", diffs)
+	}
+	if !diffs[0].Sensitive || diffs[1].Sensitive {
+		t.Errorf("expected only email_hash to be sensitive, got %+v", diffs)
+	}
+	expected := "~ email_hash: [REDACTED] -> [REDACTED]\n~ segment: gold -> silver"
+	if s := diffs.String(); s != expected {
+		t.Errorf("expected %q, got %q", expected, s)
+	}
+}
+
+func TestSchema_SensitiveFeatures(t *testing.T) {
+	schema := &Schema{
+		EntityType: "my_customer",
+		Features: []FeatureSchema{
+			{ID: "segment", ValueType: StringType, Labels: map[string]string{SensitivityLabel: "public"}},
+			{ID: "geo", ValueType: StringType, Labels: map[string]string{SensitivityLabel: "pii"}},
+			{ID: "email_hash", ValueType: StringType, Labels: map[string]string{SensitivityLabel: "pii"}},
+			{ID: "visits", ValueType: Int64Type},
+		},
+	}
+	if ids := schema.SensitiveFeatures(); !reflect.DeepEqual(ids, []string{"email_hash", "geo"}) {
+		t.Errorf("unexpected sensitive features %v", ids)
+	}
+
+	c := &Client{
+		cfg:     &Config{Sensitivity: map[string]SensitivityPolicy{"my_customer": {Sensitive: []string{"visits"}}}},
+		schemas: map[string]*Schema{"my_customer": schema},
+		metrics: nopMetrics{},
+		now:     time.Now,
+	}
+	expected := map[string]bool{"email_hash": true, "geo": true, "visits": true}
+	if s := c.sensitiveFeatures("my_customer"); !reflect.DeepEqual(s, expected) {
+		t.Errorf("expected %v, got %v", expected, s)
+	}
+}
+
+func TestNewConfigBuilder_SensitivityPolicy(t *testing.T) {
+	cfg, err := NewConfigBuilder().
+		WithProjectID("my-project").
+		WithFeatureStoreName("my_featurestore").
+		WithSensitivityPolicy("my_customer", SensitivityPolicy{Sensitive: []string{"geo"}}).
+		Apply()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.Sensitivity["my_customer"].Sensitive, []string{"geo"}) {
+		t.Errorf("unexpected policy %+v", cfg.Sensitivity)
+	}
+
+	_, err = NewConfigBuilder().
+		WithProjectID("my-project").
+		WithFeatureStoreName("my_featurestore").
+		WithSensitivityPolicy("my_customer", SensitivityPolicy{Restricted: map[string][]string{"Bad-ID": {"svc"}}}).
+		Apply()
+	if !errors.Is(err, ErrInvalidSensitivityPolicy) {
+		t.Errorf("expected ErrInvalidSensitivityPolicy, got %v", err)
+	}
+}
+
+func testAliasedSensitivityConfig(policy SensitivityPolicy) *Config {
+	return &Config{
+		ProjectID:        "my-project",
+		Region:           nane,
+		FeatureStoreName: "my_featurestore",
+		Aliases:          map[string]Aliases{"my_customer": {"email_hash": {"email_sha256"}}},
+		Sensitivity:      map[string]SensitivityPolicy{"my_customer": policy},
+	}
+}
+
+func TestSensitivityPolicy_Aliases(t *testing.T) {
+	type test struct {
+		name     string
+		policy   SensitivityPolicy
+		features []string
+		caller   string
+		denied   bool
+		expected []string
+	}
+	byAlias := SensitivityPolicy{Restricted: map[string][]string{"email_hash": {"fraud-service"}}}
+	byStoreID := SensitivityPolicy{Restricted: map[string][]string{"email_sha256": {"fraud-service"}}}
+	tests := []test{
+		{name: "alias policy, store ID query", policy: byAlias, features: []string{"segment", "email_sha256"}, caller: "ads-service", denied: true, expected: []string{"segment"}},
+		{name: "alias policy, alias query", policy: byAlias, features: []string{"segment", "email_hash"}, caller: "ads-service", denied: true, expected: []string{"segment"}},
+		{name: "store ID policy, alias query", policy: byStoreID, features: []string{"segment", "email_hash"}, caller: "ads-service", denied: true, expected: []string{"segment"}},
+		{name: "allowed caller", policy: byAlias, features: []string{"segment", "email_sha256"}, caller: "fraud-service", expected: []string{"segment", "email_sha256"}},
+	}
+	for _, tc := range tests {
+		cfg := testAliasedSensitivityConfig(tc.policy)
+		q := &Query{EntityType: "my_customer", EntityID: "123", Features: tc.features, Caller: tc.caller}
+		if err := q.CheckAccess(cfg); tc.denied != errors.Is(err, ErrAccessDenied) {
+			t.Errorf("%v: unexpected error %v", tc.name, err)
+		}
+		if ids := q.BuildRequest(cfg).FeatureSelector.IdMatcher.Ids; !reflect.DeepEqual(ids, tc.expected) {
+			t.Errorf("%v: expected %v, got %v", tc.name, tc.expected, ids)
+		}
+	}
+
+	values := newTestEntity(map[string]*aiplatformpb.FeatureValue{
+		"email_sha256": stringFeature("a1b2"),
+		"segment":      stringFeature("gold"),
+	})
+	view := &aiplatformpb.ReadFeatureValuesResponse_EntityView{EntityId: values.ID, Data: values.data}
+	c := &Client{cfg: testAliasedSensitivityConfig(byStoreID), metrics: nopMetrics{}, now: time.Now}
+	e, err := c.newEntity("my_customer", "ads-service", values.header, view)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Has("email_hash") || e.Has("email_sha256") {
+		t.Errorf("expected the restricted feature to be removed, got %v", e.FeatureIDs())
+	}
+}
+
+func TestClient_SensitiveFeaturesAliasedLabel(t *testing.T) {
+	schema := &Schema{
+		EntityType: "my_customer",
+		Features: []FeatureSchema{
+			{ID: "email_sha256", ValueType: StringType, Labels: map[string]string{SensitivityLabel: "pii"}},
+			{ID: "segment", ValueType: StringType},
+		},
+	}
+	c := &Client{
+		cfg:     testAliasedSensitivityConfig(SensitivityPolicy{}),
+		schemas: map[string]*Schema{"my_customer": schema},
+		metrics: nopMetrics{},
+		now:     time.Now,
+	}
+	values := newTestEntity(map[string]*aiplatformpb.FeatureValue{
+		"email_sha256": stringFeature("a1b2"),
+		"segment":      stringFeature("gold"),
+	})
+	view := &aiplatformpb.ReadFeatureValuesResponse_EntityView{EntityId: values.ID, Data: values.data}
+	e, err := c.newEntity("my_customer", "", values.header, view)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !e.Sensitive("email_hash") || e.Sensitive("segment") {
+		t.Errorf("expected only email_hash to be sensitive, got %v", e.sensitive)
+	}
+	if s := fmt.Sprint(e); strings.Contains(s, "a1b2") {
+		t.Errorf("sensitive value printed: %v", s)
+	}
+}
+
+func TestConfig_SensitivityPolicyStoreID(t *testing.T) {
+	cfg := testAliasedSensitivityConfig(SensitivityPolicy{Sensitive: []string{"email_sha256"}})
+	if err := cfg.validate(nil); !errors.Is(err, ErrInvalidSensitivityPolicy) {
+		t.Errorf("expected ErrInvalidSensitivityPolicy, got %v", err)
+	}
+	cfg = testAliasedSensitivityConfig(SensitivityPolicy{Sensitive: []string{"email_hash"}})
+	if err := cfg.validate(nil); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestApplyTransforms_Sensitive(t *testing.T) {
+	e := newTestEntity(map[string]*aiplatformpb.FeatureValue{
+		"income": doubleFeature(52000),
+		"spend":  doubleFeature(120.5),
+	})
+	e.sensitive = map[string]bool{"income": true}
+	err := applyTransforms(e, []Transform{
+		Log1p("income", "income_log"),
+		Log1p("spend", "spend_log"),
+		Clip("income_log", "income_clipped", 0, 10),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]bool{"income": true, "income_log": true, "income_clipped": true}
+	if !reflect.DeepEqual(e.sensitive, expected) {
+		t.Errorf("expected %v, got %v", expected, e.sensitive)
+	}
+	if !e.Has("spend_log") {
+		t.Errorf("expected spend_log, got %v", e.FeatureIDs())
+	}
+}
+
+func TestClient_NewEntityRestrictedShortResponse(t *testing.T) {
+	e := newTestEntity(map[string]*aiplatformpb.FeatureValue{
+		"email_hash": stringFeature("a1b2"),
+		"segment":    stringFeature("gold"),
+	})
+	view := &aiplatformpb.ReadFeatureValuesResponse_EntityView{EntityId: e.ID, Data: e.data[:1]}
+	c := &Client{cfg: testSensitivityConfig(), metrics: nopMetrics{}, now: time.Now}
+	if _, err := c.newEntity("my_customer", "fraud-service", e.header, view); !errors.Is(err, ErrDescriptorMismatch) {
+		t.Errorf("expected ErrDescriptorMismatch, got %v", err)
+	}
+}
+
+func TestApplyTransforms_SensitiveWithoutSources(t *testing.T) {
+	e := newTestEntity(map[string]*aiplatformpb.FeatureValue{
+		"income": doubleFeature(52000),
+		"spend":  doubleFeature(120.5),
+	})
+	e.sensitive = map[string]bool{"income": true}
+	constant := Transform{Targets: []string{"bias"}, Func: func(features map[string]Value) (map[string]Value, error) {
+		return map[string]Value{"bias": NewFloat64Value(1)}, nil
+	}}
+	if err := applyTransforms(e, []Transform{constant}); err != nil {
+		t.Fatal(err)
+	}
+	if !e.Sensitive("bias") {
+		t.Error("expected the output of a transform without sources to be sensitive")
+	}
+}
@@ -0,0 +1,146 @@
+package vertigo
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Counters emitted by shadow reads.
+const (
+	// MetricShadowReads counts the shadow reads that were issued.
+	MetricShadowReads = "shadow_reads"
+	// MetricShadowErrors counts the shadow reads that failed.
+	MetricShadowErrors = "shadow_errors"
+	// MetricShadowDropped counts the sampled reads that were not shadowed because MaxInFlight
+	// shadow reads were already running.
+	MetricShadowDropped = "shadow_dropped"
+	// MetricShadowMismatches counts, per feature, the shadow reads whose value differs from
+	// the primary read.
+	MetricShadowMismatches = "shadow_mismatches"
+)
+
+// DefaultShadowTimeout is the timeout of a shadow read when ShadowOptions does not set one.
+const DefaultShadowTimeout = 5 * time.Second
+
+// DefaultShadowMaxInFlight is the number of concurrent shadow reads when ShadowOptions does not
+// set MaxInFlight.
+const DefaultShadowMaxInFlight = 16
+
+// EntityReader reads an Entity. It is implemented by Client and Router, and can be implemented
+// to shadow reads against a store that vertigo does not read from, such as a legacy store.
+type EntityReader interface {
+	GetEntity(ctx context.Context, query *Query) (*Entity, error)
+}
+
+// ShadowOptions controls the shadow reads of WithShadow.
+type ShadowOptions struct {
+	// Fraction is the fraction of GetEntity calls that are shadowed, between 0 and 1.
+	Fraction float64
+
+	// Timeout bounds each shadow read. It defaults to DefaultShadowTimeout.
+	Timeout time.Duration
+
+	// MaxInFlight bounds the number of concurrent shadow reads; sampled reads beyond it are
+	// dropped. It defaults to DefaultShadowMaxInFlight.
+	MaxInFlight int
+
+	// OnResult, when set, receives the result of every shadow read. It is called from the
+	// goroutine of the shadow read.
+	OnResult func(ShadowResult)
+
+	// DiffOptions configure the comparison of the entities, e.g. FloatTolerance, or
+	// IgnoreGenerateTime when the stores ingested the values at different times.
+	DiffOptions []DiffOption
+}
+
+// ShadowResult is the outcome of a shadow read.
+type ShadowResult struct {
+	Query   *Query
+	Primary *Entity
+
+	// Shadow is the Entity read from the secondary, or nil when Err is set.
+	Shadow *Entity
+	Err    error
+
+	// Diffs are the differences between Primary and Shadow, as reported by Diff.
+	Diffs FeatureDiffs
+}
+
+// shadow issues the shadow reads of a Client.
+type shadow struct {
+	secondary EntityReader
+	opts      ShadowOptions
+	sample    func() float64
+	inFlight  chan struct{}
+	wg        sync.WaitGroup
+}
+
+// WithShadow makes the Client repeat a sampled fraction of its successful GetEntity calls
+// against secondary, e.g. while migrating to another featurestore. Shadow reads run
+// asynchronously once the primary read returns, so they never add to its latency, and their
+// differences are reported through OnResult and the MetricShadow counters. Close waits for
+// the shadow reads in flight.
+func WithShadow(secondary EntityReader, opts ShadowOptions) ClientOption {
+	return func(c *Client) {
+		if opts.Timeout <= 0 {
+			opts.Timeout = DefaultShadowTimeout
+		}
+		if opts.MaxInFlight <= 0 {
+			opts.MaxInFlight = DefaultShadowMaxInFlight
+		}
+		c.shadow = &shadow{
+			secondary: secondary,
+			opts:      opts,
+			sample:    rand.Float64,
+			inFlight:  make(chan struct{}, opts.MaxInFlight),
+		}
+	}
+}
+
+// shadowRead starts a shadow read of query, whose primary read returned primary, when the call
+// is sampled and a shadow read slot is free.
+func (c *Client) shadowRead(query *Query, primary *Entity) {
+	s := c.shadow
+	if s == nil || s.sample() >= s.opts.Fraction {
+		return
+	}
+	select {
+	case s.inFlight <- struct{}{}:
+	default:
+		c.metrics.Inc(MetricShadowDropped, query.EntityType, "")
+		return
+	}
+
+	query = query.clone()
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() { <-s.inFlight }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), s.opts.Timeout)
+		defer cancel()
+		c.metrics.Inc(MetricShadowReads, query.EntityType, "")
+		result := ShadowResult{Query: query, Primary: primary}
+		result.Shadow, result.Err = s.secondary.GetEntity(ctx, query)
+		if result.Err != nil {
+			c.metrics.Inc(MetricShadowErrors, query.EntityType, "")
+		} else {
+			result.Diffs = Diff(primary, result.Shadow, s.opts.DiffOptions...)
+			for _, id := range result.Diffs.FeatureIDs() {
+				c.metrics.Inc(MetricShadowMismatches, query.EntityType, id)
+			}
+		}
+		if s.opts.OnResult != nil {
+			s.opts.OnResult(result)
+		}
+	}()
+}
+
+// wait blocks until the shadow reads in flight are done.
+func (s *shadow) wait() {
+	if s != nil {
+		s.wg.Wait()
+	}
+}
@@ -0,0 +1,132 @@
+package vertigo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+)
+
+// readerFunc adapts a function to the EntityReader interface.
+type readerFunc func(ctx context.Context, query *Query) (*Entity, error)
+
+func (f readerFunc) GetEntity(ctx context.Context, query *Query) (*Entity, error) {
+	return f(ctx, query)
+}
+
+func TestClient_ShadowRead(t *testing.T) {
+	primary := newTestEntity(map[string]*aiplatformpb.FeatureValue{
+		"segment":         stringFeature("gold"),
+		"six_month_spend": {Value: &aiplatformpb.FeatureValue_DoubleValue{DoubleValue: 150}},
+		"visits":          {Value: &aiplatformpb.FeatureValue_Int64Value{Int64Value: 12}},
+	})
+	secondary := newTestEntity(map[string]*aiplatformpb.FeatureValue{
+		"segment":         stringFeature("gold"),
+		"six_month_spend": {Value: &aiplatformpb.FeatureValue_DoubleValue{DoubleValue: 151}},
+		"tenure":          {Value: &aiplatformpb.FeatureValue_Int64Value{Int64Value: 3}},
+	})
+
+	type test struct {
+		name       string
+		sample     float64
+		shadowErr  error
+		results    int
+		mismatches []string
+	}
+	tests := []test{
+		{name: "sampled", sample: 0.1, results: 1, mismatches: []string{"six_month_spend", "tenure", "visits"}},
+		{name: "not sampled", sample: 0.9},
+		{name: "shadow error", sample: 0.1, shadowErr: errors.New("legacy store is down"), results: 1},
+	}
+	for _, tc := range tests {
+		counters := NewCounters()
+		c := &Client{metrics: counters}
+		var results []ShadowResult
+		WithShadow(
+			readerFunc(func(ctx context.Context, query *Query) (*Entity, error) {
+				if tc.shadowErr != nil {
+					return nil, tc.shadowErr
+				}
+				return secondary, nil
+			}),
+			ShadowOptions{Fraction: 0.5, OnResult: func(r ShadowResult) { results = append(results, r) }},
+		)(c)
+		c.shadow.sample = func() float64 { return tc.sample }
+
+		c.shadowRead(&Query{EntityType: "my_customer", EntityID: "123"}, primary)
+		c.shadow.wait()
+
+		if len(results) != tc.results {
+			t.Errorf("%v: expected %v results, got %v", tc.name, tc.results, len(results))
+			continue
+		}
+		if tc.results == 0 {
+			continue
+		}
+		if !errors.Is(results[0].Err, tc.shadowErr) || !reflect.DeepEqual(results[0].Diffs.FeatureIDs(), tc.mismatches) {
+			t.Errorf("%v: unexpected result %+v", tc.name, results[0])
+		}
+		if tc.shadowErr != nil && counters.Count(MetricShadowErrors, "my_customer", "") != 1 {
+			t.Errorf("%v: expected the error to be counted", tc.name)
+		}
+		for _, id := range tc.mismatches {
+			if counters.Count(MetricShadowMismatches, "my_customer", id) != 1 {
+				t.Errorf("%v: expected the mismatch of %v to be counted", tc.name, id)
+			}
+		}
+	}
+}
+
+func TestClient_ShadowReadDropped(t *testing.T) {
+	counters := NewCounters()
+	c := &Client{metrics: counters}
+	release := make(chan struct{})
+	WithShadow(
+		readerFunc(func(ctx context.Context, query *Query) (*Entity, error) {
+			<-release
+			return emptyEntity(query), nil
+		}),
+		ShadowOptions{Fraction: 1, MaxInFlight: 1},
+	)(c)
+
+	query := &Query{EntityType: "my_customer", EntityID: "123"}
+	c.shadowRead(query, emptyEntity(query))
+	c.shadowRead(query, emptyEntity(query))
+	close(release)
+	c.shadow.wait()
+
+	if counters.Count(MetricShadowReads, "my_customer", "") != 1 || counters.Count(MetricShadowDropped, "my_customer", "") != 1 {
+		t.Errorf("expected one shadow read and one dropped: %v", counters.Snapshot())
+	}
+}
+
+// emptyEntity returns an Entity without features for query.
+func emptyEntity(query *Query) *Entity {
+	return &Entity{header: &aiplatformpb.ReadFeatureValuesResponse_Header{EntityType: query.EntityType}, ID: query.EntityID}
+}
+
+func TestClient_ShadowReadReusedQuery(t *testing.T) {
+	c := &Client{metrics: NewCounters()}
+	release := make(chan struct{})
+	var result ShadowResult
+	WithShadow(
+		readerFunc(func(ctx context.Context, query *Query) (*Entity, error) {
+			<-release
+			return emptyEntity(query), nil
+		}),
+		ShadowOptions{Fraction: 1, OnResult: func(r ShadowResult) { result = r }},
+	)(c)
+
+	query := &Query{EntityType: "my_customer", EntityID: "1", Features: []string{"segment"}}
+	c.shadowRead(query, emptyEntity(query))
+	query.EntityID = "2"
+	query.Features[0] = "visits"
+	close(release)
+	c.shadow.wait()
+
+	if result.Shadow.ID != "1" || result.Query.EntityID != "1" || !reflect.DeepEqual(result.Query.Features, []string{"segment"}) {
+		t.Errorf("expected the shadow read of the original query, got %+v for %+v", result.Shadow, result.Query)
+	}
+}
@@ -0,0 +1,251 @@
+package vertigo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+var ErrInvalidTransform = errors.New("transform spec is not valid")
+
+// Transform computes derived features from the features of an Entity. Its Func receives the
+// value of every feature that has one, keyed by feature ID, and returns the derived values to add
+// to the Entity, which can then be scanned through vertex tags like any other feature.
+// Transforms of an entity type run in order, so later transforms can use the output of earlier
+// ones.
+type Transform struct {
+	// Sources are the features Func reads. They are requested in place of the Targets of a
+	// query, so reading a derived feature also reads what it is derived from.
+	Sources []string
+
+	// Targets are the features Func derives. The Client does not request them from the feature
+	// store, and treats them as known features when validating queries and structs.
+	Targets []string
+
+	Func func(features map[string]Value) (map[string]Value, error)
+}
+
+// Transform types available in a TransformSpec.
+const (
+	TransformLog1p     = "log1p"
+	TransformClip      = "clip"
+	TransformBucketize = "bucketize"
+	TransformOneHot    = "one_hot"
+)
+
+// TransformSpec declares one of the built-in transforms, so derived features can be configured
+// next to the rest of the Config, e.g. in YAML:
+//
+//	transforms:
+//	  my_customer:
+//	    - type: log1p
+//	      source: six_month_spend
+//	      target: six_month_spend_log
+//	    - type: one_hot
+//	      source: segment
+//	      target: segment_one_hot
+//	      categories: [gold, silver, bronze]
+type TransformSpec struct {
+	Type       string    `json:"type" yaml:"type"`
+	Source     string    `json:"source" yaml:"source"`
+	Target     string    `json:"target" yaml:"target"`
+	Min        float64   `json:"min,omitempty" yaml:"min,omitempty"`
+	Max        float64   `json:"max,omitempty" yaml:"max,omitempty"`
+	Boundaries []float64 `json:"boundaries,omitempty" yaml:"boundaries,omitempty"`
+	Categories []string  `json:"categories,omitempty" yaml:"categories,omitempty"`
+}
+
+// Build returns the Transform declared by the spec.
+func (s TransformSpec) Build() (Transform, error) {
+	if s.Source == "" || s.Target == "" {
+		return Transform{}, fmt.Errorf("%w: %v requires a source and a target", ErrInvalidTransform, s.Type)
+	}
+	switch s.Type {
+	case TransformLog1p:
+		return Log1p(s.Source, s.Target), nil
+	case TransformClip:
+		if s.Min > s.Max {
+			return Transform{}, fmt.Errorf("%w: clip min %v is greater than max %v", ErrInvalidTransform, s.Min, s.Max)
+		}
+		return Clip(s.Source, s.Target, s.Min, s.Max), nil
+	case TransformBucketize:
+		if !sort.Float64sAreSorted(s.Boundaries) {
+			return Transform{}, fmt.Errorf("%w: bucketize boundaries must be sorted", ErrInvalidTransform)
+		}
+		return Bucketize(s.Source, s.Target, s.Boundaries), nil
+	case TransformOneHot:
+		return OneHot(s.Source, s.Target, s.Categories), nil
+	}
+	return Transform{}, fmt.Errorf("%w: unknown type %q", ErrInvalidTransform, s.Type)
+}
+
+// Log1p derives a DOUBLE target holding ln(1+x) of a numeric source feature.
+func Log1p(source, target string) Transform {
+	return numericTransform(source, target, func(x float64) Value {
+		return NewFloat64Value(math.Log1p(x))
+	})
+}
+
+// Clip derives a DOUBLE target holding a numeric source feature limited to [min, max].
+func Clip(source, target string, min, max float64) Transform {
+	return numericTransform(source, target, func(x float64) Value {
+		return NewFloat64Value(math.Max(min, math.Min(max, x)))
+	})
+}
+
+// Bucketize derives an INT64 target holding the index of the bucket a numeric source feature
+// falls in. boundaries must be sorted; bucket i holds values in [boundaries[i-1], boundaries[i]).
+func Bucketize(source, target string, boundaries []float64) Transform {
+	return numericTransform(source, target, func(x float64) Value {
+		return NewInt64Value(int64(sort.Search(len(boundaries), func(i int) bool {
+			return x < boundaries[i]
+		})))
+	})
+}
+
+// OneHot derives a DOUBLE_ARRAY target with one element per category, set to 1 for the
+// category matching the STRING source feature and 0 otherwise.
+func OneHot(source, target string, categories []string) Transform {
+	derive := func(features map[string]Value) (map[string]Value, error) {
+		v, ok := features[source]
+		if !ok {
+			return nil, nil
+		}
+		s, ok := v.AsString()
+		if !ok {
+			return nil, fmt.Errorf("one hot encoding %v: expected a STRING feature, got %v", source, v.Type())
+		}
+		encoded := make([]float64, len(categories))
+		for i, c := range categories {
+			if c == s {
+				encoded[i] = 1
+			}
+		}
+		return map[string]Value{target: NewFloat64SliceValue(encoded)}, nil
+	}
+	return Transform{Sources: []string{source}, Targets: []string{target}, Func: derive}
+}
+
+// numericTransform derives target from an INT64 or DOUBLE source feature. Nothing is derived
+// when the source has no value.
+func numericTransform(source, target string, f func(x float64) Value) Transform {
+	derive := func(features map[string]Value) (map[string]Value, error) {
+		v, ok := features[source]
+		if !ok {
+			return nil, nil
+		}
+		x, ok := v.AsFloat64()
+		if !ok {
+			i, isInt := v.AsInt64()
+			if !isInt {
+				return nil, fmt.Errorf("%v: expected a numeric feature, got %v", source, v.Type())
+			}
+			x = float64(i)
+		}
+		return map[string]Value{target: f(x)}, nil
+	}
+	return Transform{Sources: []string{source}, Targets: []string{target}, Func: derive}
+}
+
+// checkTargets returns an error wrapping ErrInvalidTransform when derived holds a feature that is
+// not one of the Targets of t.
+func (t Transform) checkTargets(derived map[string]Value) error {
+	for id := range derived {
+		if !contains(t.Targets, id) {
+			return fmt.Errorf("%w: derived %v, which is not one of its targets %v", ErrInvalidTransform, id, t.Targets)
+		}
+	}
+	return nil
+}
+
+// transformTargets returns the targets of transforms, mapped to the sources they are derived
+// from.
+func transformTargets(transforms []Transform) map[string][]string {
+	targets := map[string][]string{}
+	for _, t := range transforms {
+		for _, id := range t.Targets {
+			targets[id] = append(targets[id], t.Sources...)
+		}
+	}
+	return targets
+}
+
+// withoutTargets returns features with the targets of transforms replaced by their sources, so
+// derived features are not requested from the feature store.
+func withoutTargets(features []string, transforms []Transform) []string {
+	targets := transformTargets(transforms)
+	if len(targets) == 0 {
+		return features
+	}
+	var out []string
+	seen := map[string]bool{}
+	var add func(id string)
+	add = func(id string) {
+		if seen[id] {
+			return
+		}
+		seen[id] = true
+		sources, ok := targets[id]
+		if !ok || contains(sources, id) {
+			out = append(out, id)
+		}
+		for _, source := range sources {
+			add(source)
+		}
+	}
+	for _, id := range features {
+		add(id)
+	}
+	return out
+}
+
+// applyTransforms runs transforms in order, adding their derived features to e. Derived features
+// are sensitive when one of the Sources of their transform is, or when a transform without
+// Sources runs on an Entity with sensitive features.
+func applyTransforms(e *Entity, transforms []Transform) error {
+	features := map[string]Value{}
+	for _, id := range e.FeatureIDs() {
+		if v, ok := e.Value(id); ok {
+			features[id] = v
+		}
+	}
+
+	for _, t := range transforms {
+		derived, err := t.Func(features)
+		if err != nil {
+			return err
+		}
+		if err := t.checkTargets(derived); err != nil {
+			return err
+		}
+		sensitive := t.readsSensitive(e)
+		ids := make([]string, 0, len(derived))
+		for id := range derived {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			features[id] = derived[id]
+			e.setValue(id, derived[id])
+			if sensitive {
+				e.markSensitive(id)
+			}
+		}
+	}
+	return nil
+}
+
+// readsSensitive reports whether t reads a sensitive feature of e. A transform without Sources
+// may read any feature.
+func (t Transform) readsSensitive(e *Entity) bool {
+	if len(t.Sources) == 0 {
+		return len(e.sensitive) > 0
+	}
+	for _, id := range t.Sources {
+		if e.Sensitive(id) {
+			return true
+		}
+	}
+	return false
+}
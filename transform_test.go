@@ -0,0 +1,171 @@
+package vertigo
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+)
+
+func TestApplyTransforms(t *testing.T) {
+	entity := newTestEntity(map[string]*aiplatformpb.FeatureValue{
+		"segment":         stringFeature("silver"),
+		"six_month_spend": {Value: &aiplatformpb.FeatureValue_DoubleValue{DoubleValue: 150}},
+		"visits":          {Value: &aiplatformpb.FeatureValue_Int64Value{Int64Value: 12}},
+	})
+	header := entity.header
+
+	transforms := []Transform{
+		Log1p("six_month_spend", "six_month_spend_log"),
+		Clip("visits", "visits_clipped", 0, 10),
+		Bucketize("six_month_spend", "spend_bucket", []float64{100, 200, 500}),
+		OneHot("segment", "segment_one_hot", []string{"gold", "silver", "bronze"}),
+		Log1p("missing", "missing_log"),
+		// derived features are visible to later transforms
+		Clip("six_month_spend_log", "six_month_spend_log_clipped", 0, 1),
+	}
+	if err := applyTransforms(entity, transforms); err != nil {
+		t.Fatal(err)
+	}
+
+	type derived struct {
+		SpendLog        float64   `vertex:"six_month_spend_log"`
+		SpendLogClipped float64   `vertex:"six_month_spend_log_clipped"`
+		VisitsClipped   float64   `vertex:"visits_clipped"`
+		SpendBucket     int64     `vertex:"spend_bucket"`
+		SegmentOneHot   []float64 `vertex:"segment_one_hot"`
+	}
+	d := derived{}
+	if err := entity.ScanStruct(&d); err != nil {
+		t.Fatal(err)
+	}
+	if d.SpendLog != math.Log1p(150) || d.SpendLogClipped != 1 || d.VisitsClipped != 10 || d.SpendBucket != 1 {
+		t.Errorf("unexpected derived features: %+v", d)
+	}
+	if len(d.SegmentOneHot) != 3 || d.SegmentOneHot[1] != 1 || d.SegmentOneHot[0] != 0 {
+		t.Errorf("unexpected one hot encoding: %v", d.SegmentOneHot)
+	}
+	if entity.Has("missing_log") {
+		t.Error("nothing should be derived from a missing feature")
+	}
+	if len(header.FeatureDescriptors) != 3 {
+		t.Error("the original header should not be modified")
+	}
+
+	err := applyTransforms(entity, []Transform{Log1p("segment", "segment_log")})
+	if err == nil {
+		t.Error("expected an error for a non-numeric source")
+	}
+}
+
+func TestTransformSpec_Build(t *testing.T) {
+	type test struct {
+		name string
+		spec TransformSpec
+		err  error
+	}
+
+	tests := []test{
+		{name: "log1p", spec: TransformSpec{Type: TransformLog1p, Source: "a", Target: "b"}},
+		{name: "one hot", spec: TransformSpec{Type: TransformOneHot, Source: "a", Target: "b", Categories: []string{"x"}}},
+		{name: "unknown", spec: TransformSpec{Type: "sqrt", Source: "a", Target: "b"}, err: ErrInvalidTransform},
+		{name: "no target", spec: TransformSpec{Type: TransformLog1p, Source: "a"}, err: ErrInvalidTransform},
+		{name: "bad clip", spec: TransformSpec{Type: TransformClip, Source: "a", Target: "b", Min: 2, Max: 1}, err: ErrInvalidTransform},
+		{name: "unsorted", spec: TransformSpec{Type: TransformBucketize, Source: "a", Target: "b", Boundaries: []float64{2, 1}}, err: ErrInvalidTransform},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.spec.Build()
+			if !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+				t.Errorf("%v: expected %v, got %v", tc.name, tc.err, err)
+			}
+		})
+	}
+
+	_, err := NewConfigBuilder().
+		WithProjectID("my-project").
+		WithFeatureStoreName("my_featurestore").
+		WithTransforms("my_customer", TransformSpec{Type: "sqrt", Source: "a", Target: "b"}).
+		Apply()
+	if !errors.Is(err, ErrInvalidTransform) {
+		t.Errorf("expected ErrInvalidTransform from Apply, got %v", err)
+	}
+}
+
+func TestClient_GetEntityDerivedTags(t *testing.T) {
+	cfg := &Config{
+		ProjectID:        "my-project",
+		Region:           "us-central1",
+		FeatureStoreName: "my_featurestore",
+		Transforms: map[string][]TransformSpec{
+			"my_customer": {
+				{Type: TransformLog1p, Source: "six_month_spend", Target: "six_month_spend_log"},
+				{Type: TransformClip, Source: "six_month_spend_log", Target: "six_month_spend_log_clipped", Max: 1},
+			},
+		},
+	}
+	schema := &Schema{EntityType: "my_customer", Features: []FeatureSchema{
+		{ID: "segment", ValueType: StringType},
+		{ID: "six_month_spend", ValueType: DoubleType},
+	}}
+	c := newTestFailoverClient(t, cfg, map[string]func(ctx context.Context) error{
+		"us-central1": func(ctx context.Context) error { return nil },
+	}, WithQueryValidation(schema))
+	var requested []string
+	c.failover.targets[0].read = func(ctx context.Context, req *aiplatformpb.ReadFeatureValuesRequest) (*aiplatformpb.ReadFeatureValuesResponse, error) {
+		requested = req.FeatureSelector.IdMatcher.Ids
+		e := newTestEntity(map[string]*aiplatformpb.FeatureValue{
+			"segment":         stringFeature("gold"),
+			"six_month_spend": doubleFeature(150),
+		})
+		return &aiplatformpb.ReadFeatureValuesResponse{
+			Header:     e.header,
+			EntityView: &aiplatformpb.ReadFeatureValuesResponse_EntityView{EntityId: req.EntityId, Data: e.data},
+		}, nil
+	}
+
+	type customer struct {
+		Segment         string  `vertex:"segment"`
+		SpendLogClipped float64 `vertex:"six_month_spend_log_clipped"`
+	}
+	q, err := NewQuery().EntityType("my_customer").ID("123").FeaturesFrom(&customer{}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := c.GetEntity(context.Background(), q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(requested, []string{"segment", "six_month_spend"}) {
+		t.Errorf("expected the sources of derived features to be requested, got %v", requested)
+	}
+	dst := customer{}
+	if err := e.ScanStruct(&dst); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Segment != "gold" || dst.SpendLogClipped != 1 {
+		t.Errorf("unexpected customer %+v", dst)
+	}
+
+	report, err := validateStruct(schema, &customer{}, nil, transformTargets(c.transforms["my_customer"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := report.Err(); err != nil {
+		t.Errorf("expected derived tags to be known, got %v", err)
+	}
+}
+
+func TestApplyTransforms_UndeclaredTarget(t *testing.T) {
+	e := newTestEntity(map[string]*aiplatformpb.FeatureValue{"visits": {Value: &aiplatformpb.FeatureValue_Int64Value{Int64Value: 12}}})
+	undeclared := Transform{Targets: []string{"visits_log"}, Func: func(features map[string]Value) (map[string]Value, error) {
+		return map[string]Value{"visits_sqrt": NewFloat64Value(3)}, nil
+	}}
+	if err := applyTransforms(e, []Transform{undeclared}); !errors.Is(err, ErrInvalidTransform) {
+		t.Errorf("expected ErrInvalidTransform, got %v", err)
+	}
+}
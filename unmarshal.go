@@ -0,0 +1,111 @@
+package vertigo
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+	"google.golang.org/protobuf/proto"
+)
+
+// Tag options that select the decoder used for a STRING or BYTES feature.
+const (
+	textOption  = "text"
+	jsonOption  = "json"
+	protoOption = "proto"
+)
+
+// FeatureUnmarshaler is implemented by types that can decode themselves from a
+// FeatureValue, in the same spirit as sql.Scanner. ScanStruct prefers it over the
+// default assignment rules whenever a struct field's type implements it.
+type FeatureUnmarshaler interface {
+	UnmarshalFeature(fv *aiplatformpb.FeatureValue) error
+}
+
+var (
+	featureUnmarshalerType = reflect.TypeOf((*FeatureUnmarshaler)(nil)).Elem()
+	textUnmarshalerType    = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	jsonUnmarshalerType    = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	protoMessageType       = reflect.TypeOf((*proto.Message)(nil)).Elem()
+)
+
+// scanField loads fv into structField. Types implementing FeatureUnmarshaler decode
+// themselves, fields tagged with `text`, `json` or `proto` are decoded from the raw
+// STRING or BYTES value, and everything else is assigned by setStructField.
+func scanField(fv *aiplatformpb.FeatureValue, structField reflect.Value, lookup valueMapper) error {
+	if u, ok := implementer(structField, featureUnmarshalerType); ok {
+		return u.(FeatureUnmarshaler).UnmarshalFeature(fv)
+	}
+
+	switch {
+	case lookup.options.has(textOption):
+		u, ok := implementer(structField, textUnmarshalerType)
+		if !ok {
+			return fmt.Errorf("field %v does not implement encoding.TextUnmarshaler", lookup.fieldName)
+		}
+		raw, err := rawBytes(fv)
+		if err != nil {
+			return err
+		}
+		return u.(encoding.TextUnmarshaler).UnmarshalText(raw)
+
+	case lookup.options.has(jsonOption):
+		raw, err := rawBytes(fv)
+		if err != nil {
+			return err
+		}
+		if u, ok := implementer(structField, jsonUnmarshalerType); ok {
+			return u.(json.Unmarshaler).UnmarshalJSON(raw)
+		}
+		if !structField.CanAddr() {
+			return fmt.Errorf("field %v is not addressable", lookup.fieldName)
+		}
+		return json.Unmarshal(raw, structField.Addr().Interface())
+
+	case lookup.options.has(protoOption):
+		u, ok := implementer(structField, protoMessageType)
+		if !ok {
+			return fmt.Errorf("field %v does not implement proto.Message", lookup.fieldName)
+		}
+		raw, err := rawBytes(fv)
+		if err != nil {
+			return err
+		}
+		return proto.Unmarshal(raw, u.(proto.Message))
+	}
+
+	setStructField(fv, structField)
+	return nil
+}
+
+// implementer returns a pointer to structField as an interface{} when that pointer
+// implements iface. Nil pointer fields are allocated so the decoder has somewhere
+// to write to.
+func implementer(structField reflect.Value, iface reflect.Type) (interface{}, bool) {
+	if isValuePointer(structField) {
+		if !structField.Type().Implements(iface) {
+			return nil, false
+		}
+		if structField.IsNil() {
+			structField.Set(reflect.New(structField.Type().Elem()))
+		}
+		return structField.Interface(), true
+	}
+	if !structField.CanAddr() || !reflect.PtrTo(structField.Type()).Implements(iface) {
+		return nil, false
+	}
+	return structField.Addr().Interface(), true
+}
+
+// rawBytes returns the payload of a STRING or BYTES feature value.
+func rawBytes(fv *aiplatformpb.FeatureValue) ([]byte, error) {
+	switch v := fv.Value.(type) {
+	case *aiplatformpb.FeatureValue_StringValue:
+		return []byte(v.StringValue), nil
+	case *aiplatformpb.FeatureValue_BytesValue:
+		return v.BytesValue, nil
+	}
+	return nil, fmt.Errorf("feature value %T cannot be decoded, expected a STRING or BYTES value", fv.Value)
+}
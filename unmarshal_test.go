@@ -0,0 +1,128 @@
+package vertigo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// csv is a FeatureUnmarshaler that splits a comma-joined STRING feature.
+type csv []string
+
+func (c *csv) UnmarshalFeature(fv *aiplatformpb.FeatureValue) error {
+	s, ok := fv.Value.(*aiplatformpb.FeatureValue_StringValue)
+	if !ok {
+		return errors.New("csv: expected a STRING value")
+	}
+	*c = strings.Split(s.StringValue, ",")
+	return nil
+}
+
+func stringFeature(s string) *aiplatformpb.FeatureValue {
+	return &aiplatformpb.FeatureValue{
+		Value: &aiplatformpb.FeatureValue_StringValue{StringValue: s},
+	}
+}
+
+func bytesFeature(b []byte) *aiplatformpb.FeatureValue {
+	return &aiplatformpb.FeatureValue{
+		Value: &aiplatformpb.FeatureValue_BytesValue{BytesValue: b},
+	}
+}
+
+func TestEntity_ScanStructDecoders(t *testing.T) {
+	type prefs struct {
+		Channel string `json:"channel"`
+	}
+	type decoded struct {
+		Audiences  csv                     `vertex:"audiences"`
+		Pointer    *csv                    `vertex:"pointer"`
+		Since      time.Time               `vertex:"since,text"`
+		Prefs      prefs                   `vertex:"prefs,json"`
+		Wrapped    *wrapperspb.StringValue `vertex:"wrapped,proto"`
+		NotDecoded string                  `vertex:"not_decoded"`
+	}
+
+	wrapped, err := proto.Marshal(wrapperspb.String("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := map[string]*aiplatformpb.FeatureValue{
+		"audiences":   stringFeature("a,b"),
+		"pointer":     stringFeature("c"),
+		"since":       stringFeature("2023-02-16T00:00:00Z"),
+		"prefs":       bytesFeature([]byte(`{"channel":"email"}`)),
+		"wrapped":     bytesFeature(wrapped),
+		"not_decoded": stringFeature("plain"),
+	}
+	entity := newTestEntity(values)
+
+	d := decoded{}
+	if err := entity.ScanStruct(&d); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Audiences) != 2 || d.Audiences[1] != "b" {
+		t.Errorf("FeatureUnmarshaler was not used: %v", d.Audiences)
+	}
+	if d.Pointer == nil || (*d.Pointer)[0] != "c" {
+		t.Errorf("FeatureUnmarshaler was not used for pointer field: %v", d.Pointer)
+	}
+	if d.Since.Year() != 2023 {
+		t.Errorf("TextUnmarshaler was not used: %v", d.Since)
+	}
+	if d.Prefs.Channel != "email" {
+		t.Errorf("json decoding was not used: %v", d.Prefs)
+	}
+	if d.Wrapped.GetValue() != "hello" {
+		t.Errorf("proto decoding was not used: %v", d.Wrapped)
+	}
+	if d.NotDecoded != "plain" {
+		t.Errorf("expected plain assignment, got %v", d.NotDecoded)
+	}
+}
+
+func TestEntity_ScanStructDecoderErrors(t *testing.T) {
+	type test struct {
+		name string
+		dst  interface{}
+	}
+
+	entity := newTestEntity(map[string]*aiplatformpb.FeatureValue{
+		"f": {Value: &aiplatformpb.FeatureValue_Int64Value{Int64Value: 1}},
+	})
+
+	tests := []test{
+		{
+			name: "unmarshaler error",
+			dst: &struct {
+				F csv `vertex:"f"`
+			}{},
+		},
+		{
+			name: "json requires string or bytes",
+			dst: &struct {
+				F map[string]string `vertex:"f,json"`
+			}{},
+		},
+		{
+			name: "text requires TextUnmarshaler",
+			dst: &struct {
+				F string `vertex:"f,text"`
+			}{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := entity.ScanStruct(tc.dst); err == nil {
+				t.Errorf("%v: expected an error", tc.name)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package vertigo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ErrSchemaMismatch is returned by ValidationReport.Err when a struct does not match the
+// schema of its entity type.
+var ErrSchemaMismatch = errors.New("struct does not match the feature store schema")
+
+// TypeMismatch describes a struct field whose Go type cannot hold the value type of the
+// feature it is tagged with.
+type TypeMismatch struct {
+	Field     string
+	FeatureID string
+	ValueType ValueType
+	GoType    reflect.Type
+}
+
+func (m TypeMismatch) String() string {
+	return fmt.Sprintf("%v (%v) cannot hold %v feature %v", m.Field, m.GoType, m.ValueType, m.FeatureID)
+}
+
+// ValidationReport is the result of validating a struct against the schema of an entity type.
+type ValidationReport struct {
+	EntityType string
+
+	// UnknownTags are the vertex tags that reference features missing from the schema.
+	UnknownTags []string
+
+	// TypeMismatches are the fields whose Go type is incompatible with their feature.
+	TypeMismatches []TypeMismatch
+
+	// Uncovered are the features of the schema that no struct field is tagged with.
+	Uncovered []string
+}
+
+// Err returns an error wrapping ErrSchemaMismatch that lists the unknown tags and type
+// mismatches, or nil when there are none. Uncovered features are not considered an error.
+func (r *ValidationReport) Err() error {
+	var problems []string
+	for _, tag := range r.UnknownTags {
+		problems = append(problems, fmt.Sprintf("tag %q references a feature that does not exist", tag))
+	}
+	for _, m := range r.TypeMismatches {
+		problems = append(problems, m.String())
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: entity type %v: %v", ErrSchemaMismatch, r.EntityType, strings.Join(problems, "; "))
+}
+
+// ValidateStruct fetches the feature definitions of entityType and reports the vertex tags of
+// sample that reference nonexistent features or have incompatible Go types, as well as the
+// features sample does not cover. The returned error is only set when the schema could not be
+// fetched or sample is not a struct pointer; use ValidationReport.Err to fail on mismatches,
+// e.g. at service startup or in tests. Tags naming an alias of the Config are checked against
+// the store features of the alias, and tags naming a feature derived by the transforms of the
+// Client are known.
+func (c *Client) ValidateStruct(ctx context.Context, entityType string, sample interface{}) (*ValidationReport, error) {
+	schema, err := c.FetchSchema(ctx, entityType)
+	if err != nil {
+		return nil, err
+	}
+	return validateStruct(schema, sample, c.cfg.Aliases[entityType], transformTargets(c.transforms[entityType]))
+}
+
+// validateStruct checks the vertex tags of sample against schema, resolving aliased tags to
+// the store features of their alias that exist in schema. Tags naming a derived feature are not
+// checked, as their type is only known once they are derived.
+func validateStruct(schema *Schema, sample interface{}, aliases Aliases, derived map[string][]string) (*ValidationReport, error) {
+	if err := isStructPointer(sample); err != nil {
+		return nil, err
+	}
+	report := &ValidationReport{EntityType: schema.EntityType}
+	mapping := loadMap(sample)
+	covered := map[string]bool{}
+
+	for featureID, lookups := range mapping {
+		if _, ok := derived[featureID]; featureID == "" || ok {
+			continue
+		}
+		storeIDs := aliases.schemaFeatureIDs([]string{featureID}, schema)
+		for _, id := range storeIDs {
+			covered[id] = true
+		}
+		fs, ok := schema.Feature(storeIDs[0])
+		if !ok {
+			report.UnknownTags = append(report.UnknownTags, featureID)
+			continue
+		}
+		for _, lookup := range lookups {
+			if !compatible(lookup, fs.ValueType) {
+				report.TypeMismatches = append(report.TypeMismatches, TypeMismatch{
+					Field:     lookup.fieldName,
+					FeatureID: featureID,
+					ValueType: fs.ValueType,
+					GoType:    lookup.t,
+				})
+			}
+		}
+	}
+	for _, f := range schema.Features {
+		if !covered[f.ID] {
+			report.Uncovered = append(report.Uncovered, f.ID)
+		}
+	}
+
+	sort.Strings(report.UnknownTags)
+	sort.Strings(report.Uncovered)
+	sort.Slice(report.TypeMismatches, func(i, j int) bool {
+		return report.TypeMismatches[i].Field < report.TypeMismatches[j].Field
+	})
+	return report, nil
+}
+
+// compatible reports whether the field described by lookup can be loaded from a feature of
+// type vt by ScanStruct.
+func compatible(lookup valueMapper, vt ValueType) bool {
+	t := lookup.t
+	switch {
+	case lookup.options.has(timestampOption):
+		return t == timeType || t == reflect.PtrTo(timeType)
+	case t.Implements(featureUnmarshalerType) || reflect.PtrTo(t).Implements(featureUnmarshalerType):
+		return true
+	case lookup.options.has(textOption):
+		return (vt == StringType || vt == BytesType) && implements(t, textUnmarshalerType)
+	case lookup.options.has(protoOption):
+		return (vt == StringType || vt == BytesType) && implements(t, protoMessageType)
+	case lookup.options.has(jsonOption):
+		return vt == StringType || vt == BytesType
+	}
+
+	scalar := func(k reflect.Kind, ptr reflect.Type) bool {
+		return t.Kind() == k || t == ptr
+	}
+	switch vt {
+	case BoolType:
+		return scalar(reflect.Bool, reflect.TypeOf((*bool)(nil)))
+	case Int64Type:
+		return scalar(reflect.Int64, reflect.TypeOf((*int64)(nil))) ||
+			t.Kind() == reflect.Int || t.Kind() == reflect.Int32
+	case DoubleType:
+		return scalar(reflect.Float64, reflect.TypeOf((*float64)(nil))) || t.Kind() == reflect.Float32
+	case StringType:
+		return scalar(reflect.String, reflect.TypeOf((*string)(nil)))
+	case BytesType:
+		return t == reflect.TypeOf([]byte(nil))
+	case BoolArrayType:
+		return t == reflect.TypeOf([]bool(nil))
+	case Int64ArrayType:
+		return t == reflect.TypeOf([]int64(nil))
+	case DoubleArrayType:
+		return t == reflect.TypeOf([]float64(nil))
+	case StringArrayType:
+		return t == reflect.TypeOf([]string(nil))
+	}
+	return false
+}
+
+// implements reports whether scanField can decode into a field of type t through iface, which
+// it calls on pointer fields directly and on the address of other fields.
+func implements(t reflect.Type, iface reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		return t.Implements(iface)
+	}
+	return reflect.PtrTo(t).Implements(iface)
+}
@@ -0,0 +1,73 @@
+package vertigo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func testSchema() *Schema {
+	return &Schema{
+		EntityType: "my_customer",
+		Features: []FeatureSchema{
+			{ID: "segment", ValueType: StringType},
+			{ID: "market_audiences", ValueType: StringArrayType},
+			{ID: "six_month_spend", ValueType: DoubleType},
+			{ID: "another_numeric_feature", ValueType: Int64Type},
+			{ID: "preferences", ValueType: BytesType},
+		},
+	}
+}
+
+func TestValidateStruct(t *testing.T) {
+	type valid struct {
+		Segment         string                 `vertex:"segment"`
+		SegmentTime     time.Time              `vertex:"segment,timestamp"`
+		MarketAudiences []string               `vertex:"market_audiences"`
+		SixMonthSpend   *float64               `vertex:"six_month_spend"`
+		Preferences     map[string]string      `vertex:"preferences,json"`
+		PreferencesText time.Time              `vertex:"preferences,text"`
+		PreferencesPB   *wrapperspb.BytesValue `vertex:"preferences,proto"`
+		Remaining       map[string]interface{} `vertex:",remaining"`
+	}
+	report, err := validateStruct(testSchema(), &valid{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Err() != nil {
+		t.Errorf("expected no errors, got %v", report.Err())
+	}
+	if len(report.Uncovered) != 1 || report.Uncovered[0] != "another_numeric_feature" {
+		t.Errorf("unexpected uncovered features: %v", report.Uncovered)
+	}
+
+	type invalid struct {
+		Segmnet               string `vertex:"segmnet"`
+		SixMonthSpend         int64  `vertex:"six_month_spend"`
+		AnotherNumericFeature string `vertex:"another_numeric_feature"`
+		SegmentTime           string `vertex:"segment,timestamp"`
+		SegmentText           string `vertex:"segment,text"`
+		Preferences           string `vertex:"preferences,proto"`
+	}
+	report, err = validateStruct(testSchema(), &invalid{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.UnknownTags) != 1 || report.UnknownTags[0] != "segmnet" {
+		t.Errorf("unexpected unknown tags: %v", report.UnknownTags)
+	}
+	if len(report.TypeMismatches) != 5 {
+		t.Errorf("expected 5 type mismatches, got %v", report.TypeMismatches)
+	}
+	err = report.Err()
+	if !errors.Is(err, ErrSchemaMismatch) || !strings.Contains(err.Error(), "segmnet") {
+		t.Errorf("expected ErrSchemaMismatch naming the bad tag, got %v", err)
+	}
+
+	if _, err := validateStruct(testSchema(), invalid{}, nil, nil); err == nil {
+		t.Error("expected an error for a non-pointer sample")
+	}
+}
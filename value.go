@@ -0,0 +1,265 @@
+package vertigo
+
+import (
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ValueType is the type of a feature value, mirroring the value types of the feature store.
+type ValueType int
+
+const (
+	UnknownType ValueType = iota
+	BoolType
+	BoolArrayType
+	DoubleType
+	DoubleArrayType
+	Int64Type
+	Int64ArrayType
+	StringType
+	StringArrayType
+	BytesType
+)
+
+var valueTypeNames = map[ValueType]string{
+	UnknownType:     "VALUE_TYPE_UNSPECIFIED",
+	BoolType:        "BOOL",
+	BoolArrayType:   "BOOL_ARRAY",
+	DoubleType:      "DOUBLE",
+	DoubleArrayType: "DOUBLE_ARRAY",
+	Int64Type:       "INT64",
+	Int64ArrayType:  "INT64_ARRAY",
+	StringType:      "STRING",
+	StringArrayType: "STRING_ARRAY",
+	BytesType:       "BYTES",
+}
+
+// valueTypeFromProto converts the ValueType of a Feature resource.
+func valueTypeFromProto(t aiplatformpb.Feature_ValueType) ValueType {
+	for vt, name := range valueTypeNames {
+		if name == t.String() {
+			return vt
+		}
+	}
+	return UnknownType
+}
+
+// String returns the feature store name of the value type, e.g. "STRING_ARRAY".
+func (t ValueType) String() string {
+	if name, ok := valueTypeNames[t]; ok {
+		return name
+	}
+	return valueTypeNames[UnknownType]
+}
+
+// MarshalText encodes the value type as its feature store name.
+func (t ValueType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText decodes a feature store value type name such as "DOUBLE_ARRAY".
+func (t *ValueType) UnmarshalText(text []byte) error {
+	for vt, name := range valueTypeNames {
+		if name == string(text) {
+			*t = vt
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown value type %q", text)
+}
+
+// Value is a single feature value read from the feature store. The zero Value is of
+// UnknownType and holds nothing.
+type Value struct {
+	typ          ValueType
+	v            interface{}
+	generateTime time.Time
+}
+
+// valueFromProto converts a FeatureValue into a Value. A nil fv yields the zero Value.
+func valueFromProto(fv *aiplatformpb.FeatureValue) Value {
+	val := Value{}
+	if ts := fv.GetMetadata().GetGenerateTime(); ts != nil {
+		val.generateTime = ts.AsTime()
+	}
+
+	switch fv.GetValue().(type) {
+	case *aiplatformpb.FeatureValue_BoolValue:
+		val.typ, val.v = BoolType, fv.GetBoolValue()
+	case *aiplatformpb.FeatureValue_BoolArrayValue:
+		val.typ, val.v = BoolArrayType, fv.GetBoolArrayValue().GetValues()
+	case *aiplatformpb.FeatureValue_DoubleValue:
+		val.typ, val.v = DoubleType, fv.GetDoubleValue()
+	case *aiplatformpb.FeatureValue_DoubleArrayValue:
+		val.typ, val.v = DoubleArrayType, fv.GetDoubleArrayValue().GetValues()
+	case *aiplatformpb.FeatureValue_Int64Value:
+		val.typ, val.v = Int64Type, fv.GetInt64Value()
+	case *aiplatformpb.FeatureValue_Int64ArrayValue:
+		val.typ, val.v = Int64ArrayType, fv.GetInt64ArrayValue().GetValues()
+	case *aiplatformpb.FeatureValue_StringValue:
+		val.typ, val.v = StringType, fv.GetStringValue()
+	case *aiplatformpb.FeatureValue_StringArrayValue:
+		val.typ, val.v = StringArrayType, fv.GetStringArrayValue().GetValues()
+	case *aiplatformpb.FeatureValue_BytesValue:
+		val.typ, val.v = BytesType, fv.GetBytesValue()
+	}
+	return val
+}
+
+// NewBoolValue creates a BOOL Value.
+func NewBoolValue(v bool) Value {
+	return Value{typ: BoolType, v: v}
+}
+
+// NewInt64Value creates an INT64 Value.
+func NewInt64Value(v int64) Value {
+	return Value{typ: Int64Type, v: v}
+}
+
+// NewFloat64Value creates a DOUBLE Value.
+func NewFloat64Value(v float64) Value {
+	return Value{typ: DoubleType, v: v}
+}
+
+// NewStringValue creates a STRING Value.
+func NewStringValue(v string) Value {
+	return Value{typ: StringType, v: v}
+}
+
+// NewBytesValue creates a BYTES Value.
+func NewBytesValue(v []byte) Value {
+	return Value{typ: BytesType, v: v}
+}
+
+// NewBoolSliceValue creates a BOOL_ARRAY Value.
+func NewBoolSliceValue(v []bool) Value {
+	return Value{typ: BoolArrayType, v: v}
+}
+
+// NewInt64SliceValue creates an INT64_ARRAY Value.
+func NewInt64SliceValue(v []int64) Value {
+	return Value{typ: Int64ArrayType, v: v}
+}
+
+// NewFloat64SliceValue creates a DOUBLE_ARRAY Value.
+func NewFloat64SliceValue(v []float64) Value {
+	return Value{typ: DoubleArrayType, v: v}
+}
+
+// NewStringSliceValue creates a STRING_ARRAY Value.
+func NewStringSliceValue(v []string) Value {
+	return Value{typ: StringArrayType, v: v}
+}
+
+// toProto converts the Value into a FeatureValue. The zero Value yields nil.
+func (v Value) toProto() *aiplatformpb.FeatureValue {
+	fv := &aiplatformpb.FeatureValue{}
+	if !v.generateTime.IsZero() {
+		fv.Metadata = &aiplatformpb.FeatureValue_Metadata{GenerateTime: timestamppb.New(v.generateTime)}
+	}
+
+	switch x := v.v.(type) {
+	case bool:
+		fv.Value = &aiplatformpb.FeatureValue_BoolValue{BoolValue: x}
+	case []bool:
+		fv.Value = &aiplatformpb.FeatureValue_BoolArrayValue{BoolArrayValue: &aiplatformpb.BoolArray{Values: x}}
+	case float64:
+		fv.Value = &aiplatformpb.FeatureValue_DoubleValue{DoubleValue: x}
+	case []float64:
+		fv.Value = &aiplatformpb.FeatureValue_DoubleArrayValue{DoubleArrayValue: &aiplatformpb.DoubleArray{Values: x}}
+	case int64:
+		fv.Value = &aiplatformpb.FeatureValue_Int64Value{Int64Value: x}
+	case []int64:
+		fv.Value = &aiplatformpb.FeatureValue_Int64ArrayValue{Int64ArrayValue: &aiplatformpb.Int64Array{Values: x}}
+	case string:
+		fv.Value = &aiplatformpb.FeatureValue_StringValue{StringValue: x}
+	case []string:
+		fv.Value = &aiplatformpb.FeatureValue_StringArrayValue{StringArrayValue: &aiplatformpb.StringArray{Values: x}}
+	case []byte:
+		fv.Value = &aiplatformpb.FeatureValue_BytesValue{BytesValue: x}
+	default:
+		return nil
+	}
+	return fv
+}
+
+// Type returns the ValueType of the value.
+func (v Value) Type() ValueType {
+	return v.typ
+}
+
+// Interface returns the value as its natural Go type: bool, int64, float64, string, []byte,
+// or a slice of bool, int64, float64 or string. The zero Value returns nil.
+func (v Value) Interface() interface{} {
+	return v.v
+}
+
+// GenerateTime returns the time the value was generated, or the zero time when unknown.
+func (v Value) GenerateTime() time.Time {
+	return v.generateTime
+}
+
+// String formats the value for display.
+func (v Value) String() string {
+	if v.v == nil {
+		return "<nil>"
+	}
+	return fmt.Sprint(v.v)
+}
+
+// AsBool returns the value if it is of BoolType.
+func (v Value) AsBool() (bool, bool) {
+	b, ok := v.v.(bool)
+	return b, ok
+}
+
+// AsInt64 returns the value if it is of Int64Type.
+func (v Value) AsInt64() (int64, bool) {
+	i, ok := v.v.(int64)
+	return i, ok
+}
+
+// AsFloat64 returns the value if it is of DoubleType.
+func (v Value) AsFloat64() (float64, bool) {
+	f, ok := v.v.(float64)
+	return f, ok
+}
+
+// AsString returns the value if it is of StringType.
+func (v Value) AsString() (string, bool) {
+	s, ok := v.v.(string)
+	return s, ok
+}
+
+// AsBytes returns the value if it is of BytesType.
+func (v Value) AsBytes() ([]byte, bool) {
+	b, ok := v.v.([]byte)
+	return b, ok
+}
+
+// AsBoolSlice returns the value if it is of BoolArrayType.
+func (v Value) AsBoolSlice() ([]bool, bool) {
+	s, ok := v.v.([]bool)
+	return s, ok
+}
+
+// AsInt64Slice returns the value if it is of Int64ArrayType.
+func (v Value) AsInt64Slice() ([]int64, bool) {
+	s, ok := v.v.([]int64)
+	return s, ok
+}
+
+// AsFloat64Slice returns the value if it is of DoubleArrayType.
+func (v Value) AsFloat64Slice() ([]float64, bool) {
+	s, ok := v.v.([]float64)
+	return s, ok
+}
+
+// AsStringSlice returns the value if it is of StringArrayType.
+func (v Value) AsStringSlice() ([]string, bool) {
+	s, ok := v.v.([]string)
+	return s, ok
+}
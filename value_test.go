@@ -0,0 +1,46 @@
+package vertigo
+
+import (
+	"testing"
+
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+)
+
+func TestValueFromProto(t *testing.T) {
+	type test struct {
+		name string
+		fv   *aiplatformpb.FeatureValue
+		want ValueType
+	}
+
+	tests := []test{
+		{name: "nil", fv: nil, want: UnknownType},
+		{name: "bool", fv: &aiplatformpb.FeatureValue{Value: &aiplatformpb.FeatureValue_BoolValue{BoolValue: true}}, want: BoolType},
+		{name: "int64", fv: &aiplatformpb.FeatureValue{Value: &aiplatformpb.FeatureValue_Int64Value{Int64Value: 1}}, want: Int64Type},
+		{name: "double", fv: &aiplatformpb.FeatureValue{Value: &aiplatformpb.FeatureValue_DoubleValue{DoubleValue: 1}}, want: DoubleType},
+		{name: "string", fv: stringFeature("a"), want: StringType},
+		{name: "bytes", fv: bytesFeature([]byte("a")), want: BytesType},
+		{
+			name: "string array",
+			fv: &aiplatformpb.FeatureValue{Value: &aiplatformpb.FeatureValue_StringArrayValue{
+				StringArrayValue: &aiplatformpb.StringArray{Values: []string{"a"}},
+			}},
+			want: StringArrayType,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := valueFromProto(tc.fv)
+			if got.Type() != tc.want {
+				t.Errorf("%v: expected %v, got %v", tc.name, tc.want, got.Type())
+			}
+		})
+	}
+}
+
+func TestValueType_String(t *testing.T) {
+	if Int64ArrayType.String() != "INT64_ARRAY" || ValueType(100).String() != "VALUE_TYPE_UNSPECIFIED" {
+		t.Errorf("unexpected ValueType names: %v, %v", Int64ArrayType, ValueType(100))
+	}
+}